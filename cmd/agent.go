@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/databases"
+	"github.com/Percona-Lab/go-tpcc/helpers"
+	"github.com/Percona-Lab/go-tpcc/stats"
+	"github.com/Percona-Lab/go-tpcc/tpcc"
+
+	"github.com/spf13/cobra"
+)
+
+// types of the records an agent streams to the coordinator
+const (
+	agentStartRecord    = "start"
+	agentIntervalRecord = "interval"
+	agentSummaryRecord  = "summary"
+)
+
+// agentJob is the part of a test the coordinator assigns to an agent
+type agentJob struct {
+	Warehouses    int     `json:"warehouses"`
+	WarehouseFrom int     `json:"warehouseFrom"`
+	WarehouseTo   int     `json:"warehouseTo"`
+	ScaleFactor   float64 `json:"scalefactor"`
+	Threads       int     `json:"threads"`
+	Time          int     `json:"time"`
+	// Start is when the agent starts its workers, the clocks of coordinator and agents have to be in sync
+	Start          time.Time `json:"start"`
+	ReportInterval int       `json:"reportInterval"`
+	PercentFail    int       `json:"percentFail"`
+	// Rate is the share of the agent of an open-loop run, 0 runs closed-loop
+	Rate    float64 `json:"rate,omitempty"`
+	Arrival string  `json:"arrival,omitempty"`
+	Seed    int64   `json:"seed"`
+}
+
+// agentRecord is one line of the NDJSON stream an agent answers a job with. The start record comes first, then one
+// interval record per report interval and the summary record of the transactions after the last interval.
+type agentRecord struct {
+	Type string `json:"type"`
+	// Driver and ServerVersion are only set in the start record
+	Driver        string `json:"driver,omitempty"`
+	ServerVersion string `json:"serverVersion,omitempty"`
+	// Histograms are the encoded latencies by transaction type, see stats.Set.Encode
+	Histograms map[string]string `json:"histograms,omitempty"`
+	Failed     int64             `json:"failed"`
+	Backlog    *int              `json:"backlog,omitempty"`
+	// Outcomes and Errors of the whole run are only set in the summary record
+	Outcomes map[string]map[string]int64 `json:"outcomes,omitempty"`
+	Errors   []stats.ErrorCount          `json:"errors,omitempty"`
+}
+
+// agentCmd runs the transactions of the coordinator
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Wait for a coordinator and run the part of the test it assigns",
+	Run: func(cmd *cobra.Command, args []string) {
+
+		listen, _ := cmd.PersistentFlags().GetString("listen")
+		dbname, _ := cmd.Root().PersistentFlags().GetString("db")
+		uri, _ := cmd.Root().PersistentFlags().GetString("uri")
+		trx, _ := cmd.Root().PersistentFlags().GetBool("trx")
+		dbdriver, _ := cmd.Root().PersistentFlags().GetString("dbdriver")
+		findandmodify, _ := cmd.Root().PersistentFlags().GetBool("findandmodify")
+		trxmode, _ := cmd.Root().PersistentFlags().GetString("trx-mode")
+		aggregate, _ := cmd.Root().PersistentFlags().GetBool("aggregate")
+		debug, _ := cmd.Root().PersistentFlags().GetBool("debug")
+		user, password, tlsConfig := connection(cmd)
+
+		if dbname == "" || uri == "" {
+			panic("empty")
+		}
+
+		if trxmode != "manual" && trxmode != "callback" {
+			panic("trx-mode not correct")
+		}
+
+		caps, ok := databases.Lookup(dbdriver)
+		if !ok {
+			panic("dbdriver not correct")
+		}
+
+		if trx && !caps.Transactions {
+			panic("trx not supported by " + dbdriver)
+		}
+
+		if findandmodify && !caps.FindAndModify {
+			panic("findandmodify not supported by " + dbdriver)
+		}
+
+		if aggregate && !caps.Aggregate {
+			panic("aggregate not supported by " + dbdriver)
+		}
+
+		a := &agent{
+			base: tpcc.Configuration{
+				DBDriver:      dbdriver,
+				DBName:        dbname,
+				URI:           uri,
+				User:          user,
+				Password:      password,
+				TLS:           tlsConfig,
+				Transactions:  trx,
+				FindAndModify: findandmodify,
+				TrxMode:       trxmode,
+				Aggregate:     aggregate,
+				Debug:         debug,
+				DriverParams:  driverParams(cmd),
+			},
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/run", a.handleRun)
+
+		fmt.Printf("Waiting for the coordinator on %s\n", listen)
+		panic(http.ListenAndServe(listen, mux))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+
+	agentCmd.PersistentFlags().String("listen", ":9300", "address to wait for the coordinator on")
+}
+
+// agent runs one job of the coordinator at a time
+type agent struct {
+	base tpcc.Configuration
+
+	mu      sync.Mutex
+	running bool
+	// loaded is the dataset an in-process driver holds, it is loaded by the first job
+	loaded string
+}
+
+// handleRun runs the job of the request body and streams the records of the run back. The run is canceled if the
+// coordinator goes away.
+func (a *agent) handleRun(w http.ResponseWriter, r *http.Request) {
+	var job agentJob
+	if !readJSON(w, r, &job) {
+		return
+	}
+
+	if job.Threads < 1 || job.Time < 1 || job.ReportInterval < 1 || job.WarehouseFrom < 1 || job.WarehouseTo < job.WarehouseFrom || job.WarehouseTo > job.Warehouses || !(job.ScaleFactor > 0) {
+		http.Error(w, "invalid job", http.StatusBadRequest)
+		return
+	}
+
+	// the schedule of an open-loop job is created in the handler, it must not panic there
+	if job.Rate < 0 || job.Rate > tpcc.MaxRate || job.Rate > 0 && job.Arrival != tpcc.ConstantArrival && job.Arrival != tpcc.PoissonArrival {
+		http.Error(w, "invalid rate or arrival of the job", http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	if a.running {
+		a.mu.Unlock()
+		http.Error(w, "the agent is running another job", http.StatusConflict)
+		return
+	}
+	a.running = true
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		a.running = false
+		a.mu.Unlock()
+	}()
+
+	conf := a.base
+	conf.Threads = job.Threads
+	conf.ReportInterval = job.ReportInterval
+	conf.WareHouses = job.Warehouses
+	conf.WarehouseFrom = job.WarehouseFrom
+	conf.WarehouseTo = job.WarehouseTo
+	conf.ScaleFactor = job.ScaleFactor
+	conf.PercentFail = job.PercentFail
+
+	// in-process drivers only live as long as the process, so the data is loaded by the agent
+	if caps, _ := databases.Lookup(conf.DBDriver); caps.InProcess {
+		dataset := fmt.Sprintf("%d/%g", conf.WareHouses, conf.ScaleFactor)
+		if a.loaded != dataset {
+			load(&conf)
+			a.loaded = dataset
+		}
+	}
+
+	helpers.Seed(job.Seed)
+
+	worker, err := tpcc.NewWorker(&conf, nil, nil, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	version, err := worker.ServerVersion(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("Running warehouses %d-%d with %d threads\n", job.WarehouseFrom, job.WarehouseTo, job.Threads)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	send := func(record agentRecord) error {
+		err := enc.Encode(record)
+		if err == nil && flusher != nil {
+			flusher.Flush()
+		}
+		return err
+	}
+
+	err = send(agentRecord{Type: agentStartRecord, Driver: conf.DBDriver, ServerVersion: version})
+	if err != nil {
+		return
+	}
+
+	err = execute(r.Context(), &conf, job, send)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("... done")
+}
+
+// execute waits for the start of job and runs it, the records are passed to send. It returns the error of send or
+// of ctx, the run ends early then.
+func execute(ctx context.Context, conf *tpcc.Configuration, job agentJob, send func(agentRecord) error) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(job.Start)):
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	c := make(chan tpcc.Transaction, 1024)
+	schedule := newSchedule(job.Rate, job.Arrival)
+	workers := newPool(ctx, conf, wg, c, schedule, nil, nil)
+	workers.Resize(job.Threads)
+
+	ticker := time.NewTicker(time.Duration(job.ReportInterval) * time.Second)
+	defer ticker.Stop()
+	timeout := time.After(time.Duration(job.Time)*time.Second + 99*time.Millisecond)
+
+	latencies := stats.Set{}
+	breakdown := stats.NewBreakdown()
+	var failed int64
+
+	// record encodes the latencies and failures since the last record
+	record := func(recordType string) (agentRecord, error) {
+		histograms, err := latencies.Encode()
+		if err != nil {
+			return agentRecord{}, err
+		}
+
+		r := agentRecord{Type: recordType, Histograms: histograms, Failed: failed}
+		if schedule != nil {
+			backlog := schedule.Backlog(time.Now())
+			r.Backlog = &backlog
+		}
+
+		latencies.Reset()
+		failed = 0
+		return r, nil
+	}
+
+	var err error
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break loop
+		case <-timeout:
+			break loop
+		case v := <-c:
+			latencies.Record(v.Type.String(), v.Time)
+			breakdown.Add(v.Type.String(), v.Outcome.String(), v.ErrorClass, v.Error)
+			if v.Failed {
+				failed++
+			}
+		case <-ticker.C:
+			var r agentRecord
+			r, err = record(agentIntervalRecord)
+			if err == nil {
+				err = send(r)
+			}
+			if err != nil {
+				break loop
+			}
+		}
+	}
+
+	summary, recordErr := record(agentSummaryRecord)
+	cancel()
+
+	// the workers block on c until they see ctx is done, the transactions they finish meanwhile are not counted
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for drained := false; !drained; {
+		select {
+		case <-c:
+		case <-done:
+			drained = true
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	if recordErr != nil {
+		return recordErr
+	}
+
+	summary.Outcomes = breakdown.Outcomes
+	summary.Errors = breakdown.Errors()
+	return send(summary)
+}
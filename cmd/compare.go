@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Percona-Lab/go-tpcc/stats"
+	"github.com/Percona-Lab/go-tpcc/tpcc"
+
+	"github.com/spf13/cobra"
+)
+
+// compareCmd compares the JSON output of two runs and fails if the candidate regressed
+var compareCmd = &cobra.Command{
+	Use:   "compare base.json candidate.json",
+	Short: "Compare the --report-format json output of two runs, exits with 1 if the candidate regressed",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		throughputTolerance, _ := cmd.Flags().GetFloat64("throughput-tolerance")
+		latencyTolerance, _ := cmd.Flags().GetFloat64("latency-tolerance")
+		percentiles, _ := cmd.Flags().GetStringSlice("percentiles")
+
+		for _, p := range percentiles {
+			if _, ok := percentile(stats.Summary{}, p); !ok {
+				panic("percentiles not correct")
+			}
+		}
+
+		base, err := stats.ReadResult(args[0])
+		if err != nil {
+			panic(err)
+		}
+
+		candidate, err := stats.ReadResult(args[1])
+		if err != nil {
+			panic(err)
+		}
+
+		regressions := 0
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "METRIC\tBASE\tCANDIDATE\tCHANGE\tSTATUS")
+
+		// row prints one metric, higherIsBetter tells in which direction the tolerance applies
+		row := func(metric string, b float64, c float64, higherIsBetter bool, tolerance float64) {
+			if b == 0 {
+				fmt.Fprintf(w, "%s\t%.2f\t%.2f\t-\t-\n", metric, b, c)
+				return
+			}
+
+			change := (c - b) / b * 100
+			status := "ok"
+			if (higherIsBetter && change < -tolerance) || (!higherIsBetter && change > tolerance) {
+				status = "REGRESSION"
+				regressions++
+			}
+
+			fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%+.1f%%\t%s\n", metric, b, c, change, status)
+		}
+
+		row("tpmC", base.TpmC(), candidate.TpmC(), true, throughputTolerance)
+
+		for _, t := range tpcc.TransactionTypes {
+			row(t.String()+" tps", base.Throughput(t.String()), candidate.Throughput(t.String()), true, throughputTolerance)
+		}
+
+		if base.Latency != nil && candidate.Latency != nil {
+			for _, t := range tpcc.TransactionTypes {
+				for _, p := range percentiles {
+					b, _ := percentile(base.Latency[t.String()], p)
+					c, _ := percentile(candidate.Latency[t.String()], p)
+					row(t.String()+" "+p+" ms", b, c, false, latencyTolerance)
+				}
+			}
+		}
+
+		w.Flush()
+
+		if base.Latency == nil || candidate.Latency == nil {
+			fmt.Println("latencies not compared, the output of a run has no summary")
+		}
+
+		if regressions > 0 {
+			fmt.Printf("%d regressions\n", regressions)
+			os.Exit(1)
+		}
+	},
+}
+
+// percentile returns a value of the summary by its name in --percentiles
+func percentile(s stats.Summary, name string) (float64, bool) {
+	switch strings.ToLower(name) {
+	case "mean":
+		return s.Mean, true
+	case "p50":
+		return s.P50, true
+	case "p90":
+		return s.P90, true
+	case "p95":
+		return s.P95, true
+	case "p99":
+		return s.P99, true
+	case "p99.9":
+		return s.P999, true
+	case "max":
+		return s.Max, true
+	}
+
+	return 0, false
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+
+	compareCmd.Flags().Float64("throughput-tolerance", 5, "% the throughput of the candidate may be lower than the base")
+	compareCmd.Flags().Float64("latency-tolerance", 10, "% the latencies of the candidate may be higher than the base")
+	compareCmd.Flags().StringSlice("percentiles", []string{"p50", "p95", "p99"}, "latencies to compare (mean|p50|p90|p95|p99|p99.9|max)")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Percona-Lab/go-tpcc/databases"
+	"github.com/spf13/cobra"
+)
+
+// environment variables read when the corresponding flag is not given, so credentials stay out of process listings
+const (
+	userEnv     = "GO_TPCC_USER"
+	passwordEnv = "GO_TPCC_PASSWORD"
+)
+
+// connection returns the credentials and TLS configuration of the root flags.
+// The password is taken from --password, --password-file or GO_TPCC_PASSWORD, in this order.
+func connection(cmd *cobra.Command) (string, string, *tls.Config) {
+	user, _ := cmd.Root().PersistentFlags().GetString("user")
+	password, _ := cmd.Root().PersistentFlags().GetString("password")
+	passwordFile, _ := cmd.Root().PersistentFlags().GetString("password-file")
+	tlsCA, _ := cmd.Root().PersistentFlags().GetString("tls-ca")
+	tlsCert, _ := cmd.Root().PersistentFlags().GetString("tls-cert")
+	tlsKey, _ := cmd.Root().PersistentFlags().GetString("tls-key")
+	tlsSkipVerify, _ := cmd.Root().PersistentFlags().GetBool("tls-skip-verify")
+
+	if user == "" {
+		user = os.Getenv(userEnv)
+	}
+
+	if password != "" && passwordFile != "" {
+		panic("password and password-file are exclusive")
+	}
+
+	if passwordFile != "" {
+		b, err := ioutil.ReadFile(passwordFile)
+		if err != nil {
+			panic(err)
+		}
+		password = strings.TrimRight(string(b), "\r\n")
+	}
+
+	if password == "" {
+		password = os.Getenv(passwordEnv)
+	}
+
+	tlsConfig, err := databases.TLSConfig(tlsCA, tlsCert, tlsKey, tlsSkipVerify)
+	if err != nil {
+		panic(err)
+	}
+
+	return user, password, tlsConfig
+}
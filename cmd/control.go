@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/stats"
+	"github.com/Percona-Lab/go-tpcc/tpcc"
+)
+
+// control serves the HTTP API that changes a running test. A nil *control serves nothing and never stops the run,
+// so report doesn't have to check whether the API is enabled.
+type control struct {
+	workers  *pool
+	schedule *tpcc.Schedule
+	profile  tpcc.Profile
+	trx      *tpcc.Control
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu sync.Mutex
+	// interval and summary are the last reported interval and the run up to it, encoded when they are reported
+	interval json.RawMessage
+	summary  json.RawMessage
+}
+
+// controlStatus is the response of GET /stats
+type controlStatus struct {
+	Threads  int             `json:"threads"`
+	Rate     float64         `json:"rate,omitempty"`
+	Paused   bool            `json:"paused"`
+	Mix      tpcc.Mix        `json:"mix"`
+	Interval json.RawMessage `json:"interval"`
+	Summary  json.RawMessage `json:"summary"`
+}
+
+func newControl(workers *pool, schedule *tpcc.Schedule, profile tpcc.Profile, trx *tpcc.Control) *control {
+	return &control{
+		workers:  workers,
+		schedule: schedule,
+		profile:  profile,
+		trx:      trx,
+		stop:     make(chan struct{}),
+		interval: json.RawMessage("null"),
+		summary:  json.RawMessage("null"),
+	}
+}
+
+// Serve serves the API on addr in the background. Only failing to listen is returned.
+func (c *control) Serve(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", c.handleStats)
+	mux.HandleFunc("/threads", c.handleThreads)
+	mux.HandleFunc("/rate", c.handleRate)
+	mux.HandleFunc("/pause", c.handlePause)
+	mux.HandleFunc("/resume", c.handleResume)
+	mux.HandleFunc("/mix", c.handleMix)
+	mux.HandleFunc("/stop", c.handleStop)
+
+	go http.Serve(l, mux)
+
+	return nil
+}
+
+// Stopped is closed once POST /stop asks to end the run, it is nil for a nil *control
+func (c *control) Stopped() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+
+	return c.stop
+}
+
+// update keeps the last reported interval and the summary of the run up to it for GET /stats
+func (c *control) update(interval stats.Interval, summary stats.RunSummary) {
+	if c == nil {
+		return
+	}
+
+	i, err := json.Marshal(interval)
+	if err != nil {
+		panic(err)
+	}
+	s, err := json.Marshal(summary)
+	if err != nil {
+		panic(err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.interval = i
+	c.summary = s
+}
+
+func (c *control) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "use GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, c.status())
+}
+
+// status is the state of the run now, with the last reported interval
+func (c *control) status() controlStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return controlStatus{
+		Threads:  c.workers.Size(),
+		Rate:     c.schedule.Rate(),
+		Paused:   c.trx.Paused(),
+		Mix:      c.trx.Mix(),
+		Interval: c.interval,
+		Summary:  c.summary,
+	}
+}
+
+func (c *control) handleThreads(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Threads int `json:"threads"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	if c.profile != nil {
+		http.Error(w, "the threads follow --profile", http.StatusConflict)
+		return
+	}
+	if body.Threads < 1 {
+		http.Error(w, "threads has to be at least 1", http.StatusBadRequest)
+		return
+	}
+
+	c.workers.Resize(body.Threads)
+	writeJSON(w, c.status())
+}
+
+func (c *control) handleRate(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Rate float64 `json:"rate"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	if c.schedule == nil {
+		http.Error(w, "the rate can only be changed in a run started with --rate", http.StatusConflict)
+		return
+	}
+
+	err := c.schedule.ChangeRate(body.Rate, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, c.status())
+}
+
+func (c *control) handlePause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.trx.Pause()
+	writeJSON(w, c.status())
+}
+
+// handleResume restarts the schedule of an open-loop run, the transactions missed while paused are not made up
+func (c *control) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if c.trx.Paused() {
+		if c.schedule != nil {
+			c.schedule.Restart(time.Now())
+		}
+		c.trx.Resume()
+	}
+	writeJSON(w, c.status())
+}
+
+func (c *control) handleMix(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeJSON(w, c.trx.Mix())
+		return
+	}
+
+	var mix tpcc.Mix
+	if !readJSON(w, r, &mix) {
+		return
+	}
+
+	err := c.trx.ChangeMix(mix)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, c.trx.Mix())
+}
+
+// handleStop ends the run like --time does, the final report is printed before the process exits
+func (c *control) handleStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	writeJSON(w, c.status())
+}
+
+// readJSON decodes the body of a POST request into v, it writes the error response and returns false if it can't
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid body: %s", err), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
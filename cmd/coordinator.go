@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/stats"
+	"github.com/Percona-Lab/go-tpcc/tpcc"
+
+	"github.com/spf13/cobra"
+)
+
+// coordinatorCmd splits a test between agents and reports their merged results
+var coordinatorCmd = &cobra.Command{
+	Use:   "coordinator",
+	Short: "Run a test on several agents and report their merged results",
+	Run: func(cmd *cobra.Command, args []string) {
+
+		agents, _ := cmd.PersistentFlags().GetStringSlice("agents")
+		warehouses, _ := cmd.PersistentFlags().GetInt("warehouses")
+		threads, _ := cmd.PersistentFlags().GetInt("threads")
+		scalefactor, _ := cmd.PersistentFlags().GetFloat64("scalefactor")
+		ri, _ := cmd.PersistentFlags().GetInt("report-interval")
+		ttime, _ := cmd.PersistentFlags().GetInt("time")
+		rf_, _ := cmd.PersistentFlags().GetString("report-format")
+		perc, _ := cmd.PersistentFlags().GetInt("percentile")
+		percfail, _ := cmd.PersistentFlags().GetInt("percent-fail")
+		rate, _ := cmd.PersistentFlags().GetFloat64("rate")
+		arrival, _ := cmd.PersistentFlags().GetString("arrival")
+		startDelay, _ := cmd.PersistentFlags().GetDuration("start-delay")
+		histogramOut, _ := cmd.PersistentFlags().GetString("histogram-out")
+		dbname, _ := cmd.Root().PersistentFlags().GetString("db")
+		randSeed := seed(cmd)
+
+		if len(agents) == 0 {
+			panic("agents not correct")
+		}
+
+		if warehouses < len(agents) {
+			panic("every agent needs a warehouse")
+		}
+
+		if perc > 100 || perc < 0 {
+			panic("percentile not correct")
+		}
+
+		if rate < 0 || rate > tpcc.MaxRate {
+			panic("rate not correct")
+		}
+
+		if arrival != tpcc.ConstantArrival && arrival != tpcc.PoissonArrival {
+			panic("arrival not correct")
+		}
+
+		var rf OutputType
+		switch rf_ {
+		case "json":
+			rf = JSONOutput
+
+		case "csv":
+			rf = CSVOutput
+
+		default:
+			rf = DefaultOutput
+		}
+
+		start := time.Now().Add(startDelay)
+		streams := make([]*agentStream, len(agents))
+		for n, addr := range agents {
+			streams[n] = startAgent(addr, agentJob{
+				Warehouses:     warehouses,
+				WarehouseFrom:  n*warehouses/len(agents) + 1,
+				WarehouseTo:    (n + 1) * warehouses / len(agents),
+				ScaleFactor:    scalefactor,
+				Threads:        threads,
+				Time:           ttime,
+				Start:          start,
+				ReportInterval: ri,
+				PercentFail:    percfail,
+				Rate:           rate / float64(len(agents)),
+				Arrival:        arrival,
+				// the agents must not generate the same values
+				Seed: randSeed + int64(n),
+			})
+		}
+
+		// every agent answers with its start record first
+		var first agentRecord
+		for n, s := range streams {
+			r := s.next(agentStartRecord)
+			if n == 0 {
+				first = r
+			}
+		}
+
+		if rf == JSONOutput {
+			printJSON(stats.Header{
+				Type:          stats.HeaderRecord,
+				Version:       stats.SchemaVersion,
+				RunID:         runID(),
+				Start:         start,
+				Seed:          randSeed,
+				Driver:        first.Driver,
+				ServerVersion: first.ServerVersion,
+				Config: stats.RunConfig{
+					DB:             dbname,
+					Warehouses:     warehouses,
+					ScaleFactor:    scalefactor,
+					Threads:        threads,
+					Time:           ttime,
+					ReportInterval: ri,
+					Percentile:     float64(perc),
+					PercentFail:    percfail,
+					Rate:           rate,
+					Arrival:        arrival,
+					Agents:         len(agents),
+				},
+			})
+		}
+
+		printIntervalHeader(rf, rate > 0, false)
+		merge(streams, ri, rf, float64(perc), rate > 0, histogramOut)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(coordinatorCmd)
+
+	coordinatorCmd.PersistentFlags().StringSlice("agents", nil, "comma separated addresses of the agents, e.g. host1:9300,host2:9300")
+	coordinatorCmd.PersistentFlags().Int("threads", 8, "threads of every agent")
+	coordinatorCmd.PersistentFlags().Int("report-interval", 1, "Report interval")
+	coordinatorCmd.PersistentFlags().Int("time", 10, "How long to run the test")
+	coordinatorCmd.PersistentFlags().Int("warehouses", 10, "Number of warehouses, split evenly between the agents")
+	coordinatorCmd.PersistentFlags().Int("percentile", 95, "Percentile for latency reporting")
+	coordinatorCmd.PersistentFlags().Int("percent-fail", 0, "How much % of New Order trxs should fail [0-100]")
+	coordinatorCmd.PersistentFlags().Float64("scalefactor", 1, "Scale-factor")
+	coordinatorCmd.PersistentFlags().Float64("rate", 0, "start this many transactions per second in total, split evenly between the agents. 0 runs every thread as fast as it can")
+	coordinatorCmd.PersistentFlags().String("arrival", "constant", "arrival process of --rate (constant|poisson)")
+	coordinatorCmd.PersistentFlags().String("report-format", "default", "default|json|csv")
+	coordinatorCmd.PersistentFlags().Duration("start-delay", 3*time.Second, "time the agents get to connect before they start together")
+	coordinatorCmd.PersistentFlags().String("histogram-out", "", "write the merged latency histograms of the whole run to this file")
+}
+
+// agentStream reads the records an agent streams back for its job
+type agentStream struct {
+	addr string
+	body io.ReadCloser
+	dec  *json.Decoder
+}
+
+// startAgent sends job to the agent at addr
+func startAgent(addr string, job agentJob) *agentStream {
+	b, err := json.Marshal(job)
+	if err != nil {
+		panic(err)
+	}
+
+	url := addr
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+
+	resp, err := http.Post(strings.TrimSuffix(url, "/")+"/run", "application/json", bytes.NewReader(b))
+	if err != nil {
+		panic(fmt.Errorf("agent %s: %w", addr, err))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		panic(fmt.Errorf("agent %s: %s: %s", addr, resp.Status, strings.TrimSpace(string(msg))))
+	}
+
+	return &agentStream{addr: addr, body: resp.Body, dec: json.NewDecoder(resp.Body)}
+}
+
+// next reads the next record, which has to be one of types
+func (s *agentStream) next(types ...string) agentRecord {
+	var r agentRecord
+	err := s.dec.Decode(&r)
+	if err == io.EOF {
+		panic(fmt.Errorf("agent %s ended the run early, see its output", s.addr))
+	}
+	if err != nil {
+		panic(fmt.Errorf("agent %s: %w", s.addr, err))
+	}
+
+	for _, t := range types {
+		if r.Type == t {
+			return r
+		}
+	}
+
+	panic(fmt.Errorf("agent %s: unexpected %s record", s.addr, r.Type))
+}
+
+// merge reads the intervals of all agents at once and prints them merged like report does, then the summary of the
+// whole run. backlog tells if the agents report a backlog.
+func merge(streams []*agentStream, ri int, output OutputType, percentile float64, backlog bool, histogramOut string) {
+	i := ri
+	total := stats.Set{}
+	breakdown := stats.NewBreakdown()
+	var failed int64
+	var endBacklog int
+
+	running := streams
+	for len(running) > 0 {
+		latencies := stats.Set{}
+		interval := stats.Interval{
+			Type:         stats.IntervalRecord,
+			Time:         float64(i),
+			Transactions: map[string]stats.IntervalTransactions{},
+		}
+		intervalBacklog := 0
+		reported := false
+
+		var next []*agentStream
+		for _, s := range running {
+			r := s.next(agentIntervalRecord, agentSummaryRecord)
+
+			set, err := stats.DecodeSet(r.Histograms)
+			if err != nil {
+				panic(fmt.Errorf("agent %s: %w", s.addr, err))
+			}
+
+			failed += r.Failed
+			if r.Type == agentSummaryRecord {
+				// the summary has the transactions after the last interval, they count for the whole run only
+				total.Merge(set)
+				breakdown.Merge(r.Outcomes, r.Errors)
+				if r.Backlog != nil {
+					endBacklog += *r.Backlog
+				}
+				s.body.Close()
+				continue
+			}
+
+			latencies.Merge(set)
+			interval.Failed += r.Failed
+			if r.Backlog != nil {
+				intervalBacklog += *r.Backlog
+			}
+			reported = true
+			next = append(next, s)
+		}
+		running = next
+
+		if !reported {
+			break
+		}
+
+		var count int64
+		for _, t := range tpcc.TransactionTypes {
+			h := latencies.Get(t.String())
+			interval.Transactions[t.String()] = stats.IntervalTransactions{
+				Count:   h.Count(),
+				Latency: h.Percentile(percentile),
+			}
+			count += h.Count()
+		}
+		interval.TPS = float64(count) / float64(ri)
+		if backlog {
+			interval.Backlog = &intervalBacklog
+		}
+
+		printInterval(output, interval)
+		total.Merge(latencies)
+		i += ri
+	}
+
+	summary(total, breakdown, output, float64(i-ri), int(failed), backlog, endBacklog)
+
+	if histogramOut != "" {
+		err := total.WriteFile(histogramOut)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !no_bbolt
+// +build !no_bbolt
+
+package cmd
+
+// build with -tags no_bbolt to leave the driver out of the binary
+import _ "github.com/Percona-Lab/go-tpcc/databases/kv/boltdb"
@@ -0,0 +1,7 @@
+//go:build !no_elasticsearch
+// +build !no_elasticsearch
+
+package cmd
+
+// build with -tags no_elasticsearch to leave the driver out of the binary
+import _ "github.com/Percona-Lab/go-tpcc/databases/elasticsearch"
@@ -0,0 +1,7 @@
+//go:build !no_memory
+// +build !no_memory
+
+package cmd
+
+// build with -tags no_memory to leave the driver out of the binary
+import _ "github.com/Percona-Lab/go-tpcc/databases/memory"
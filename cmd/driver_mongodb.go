@@ -0,0 +1,7 @@
+//go:build !no_mongodb
+// +build !no_mongodb
+
+package cmd
+
+// build with -tags no_mongodb to leave the driver out of the binary
+import _ "github.com/Percona-Lab/go-tpcc/databases/mongodb"
@@ -0,0 +1,7 @@
+//go:build !no_mysql
+// +build !no_mysql
+
+package cmd
+
+// build with -tags no_mysql to leave the driver out of the binary
+import _ "github.com/Percona-Lab/go-tpcc/databases/mysql"
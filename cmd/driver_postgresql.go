@@ -0,0 +1,7 @@
+//go:build !no_postgresql
+// +build !no_postgresql
+
+package cmd
+
+// build with -tags no_postgresql to leave the driver out of the binary
+import _ "github.com/Percona-Lab/go-tpcc/databases/postgresql"
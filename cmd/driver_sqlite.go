@@ -0,0 +1,7 @@
+//go:build !no_sqlite
+// +build !no_sqlite
+
+package cmd
+
+// build with -tags no_sqlite to leave the driver out of the binary
+import _ "github.com/Percona-Lab/go-tpcc/databases/sqlite"
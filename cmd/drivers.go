@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Percona-Lab/go-tpcc/databases"
+
+	"github.com/spf13/cobra"
+)
+
+// driversCmd lists the database drivers compiled into the binary
+var driversCmd = &cobra.Command{
+	Use:   "drivers",
+	Short: "List the database drivers built into this binary and what they support",
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DRIVER\tSUPPORTS\tDESCRIPTION")
+
+		for _, name := range databases.Drivers() {
+			caps, _ := databases.Lookup(name)
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, supports(caps), caps.Description)
+		}
+
+		w.Flush()
+	},
+}
+
+// supports lists the flags and behaviours a driver implements
+func supports(caps databases.Capabilities) string {
+	var s []string
+
+	if caps.Transactions {
+		s = append(s, "trx")
+	}
+	if caps.FindAndModify {
+		s = append(s, "findandmodify")
+	}
+	if caps.Aggregate {
+		s = append(s, "aggregate")
+	}
+	if caps.Shard {
+		s = append(s, "shard")
+	}
+	if caps.Denormalized {
+		s = append(s, "denormalized")
+	}
+	if caps.InProcess {
+		s = append(s, "in-process")
+	}
+
+	if len(s) == 0 {
+		return "-"
+	}
+
+	return strings.Join(s, ",")
+}
+
+func init() {
+	rootCmd.AddCommand(driversCmd)
+}
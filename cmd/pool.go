@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/stats"
+	"github.com/Percona-Lab/go-tpcc/tpcc"
+)
+
+// pool runs the workers of a run and changes their number while it runs. Every worker connects on its own, a
+// stopped worker finishes its running transaction first.
+type pool struct {
+	mu       sync.Mutex
+	ctx      context.Context
+	base     *tpcc.Configuration
+	wg       *sync.WaitGroup
+	c        chan tpcc.Transaction
+	schedule *tpcc.Schedule
+	control  *tpcc.Control
+	metrics  *stats.Metrics
+	// stops of the running workers, the last one is stopped first
+	stops []chan struct{}
+	// next is the thread id of the next worker, ids are not reused
+	next int
+}
+
+func newPool(ctx context.Context, base *tpcc.Configuration, wg *sync.WaitGroup, c chan tpcc.Transaction, schedule *tpcc.Schedule, control *tpcc.Control, metrics *stats.Metrics) *pool {
+	return &pool{
+		ctx:      ctx,
+		base:     base,
+		wg:       wg,
+		c:        c,
+		schedule: schedule,
+		control:  control,
+		metrics:  metrics,
+	}
+}
+
+// Resize starts or stops workers until threads are running
+func (p *pool) Resize(threads int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.stops) < threads {
+		stop := make(chan struct{})
+		p.stops = append(p.stops, stop)
+
+		p.wg.Add(1)
+		go func(i int) {
+			conf := *p.base
+
+			p.metrics.WorkerStarted()
+			defer p.metrics.WorkerStopped()
+
+			w, err := tpcc.NewWorker(&conf, p.wg, p.c, i)
+			if err != nil {
+				panic(err)
+			}
+			if p.schedule != nil {
+				w.ChangeSchedule(p.schedule)
+			}
+			w.ChangeControl(p.control)
+			w.ChangeStop(stop)
+			w.Execute(p.ctx)
+		}(p.next)
+		p.next++
+	}
+
+	for len(p.stops) > threads {
+		close(p.stops[len(p.stops)-1])
+		p.stops = p.stops[:len(p.stops)-1]
+	}
+}
+
+// Size is the number of running workers
+func (p *pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.stops)
+}
+
+// follow resizes the pool to the threads of profile since start until ctx is done
+func (p *pool) follow(ctx context.Context, profile tpcc.Profile, start time.Time) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		_, threads := profile.At(time.Since(start))
+		p.Resize(threads)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/Percona-Lab/go-tpcc/databases"
 	"github.com/Percona-Lab/go-tpcc/tpcc"
 	"github.com/spf13/cobra"
 )
@@ -21,18 +22,31 @@ var prepareCmd = &cobra.Command{
 
 		uri, _ := cmd.Root().PersistentFlags().GetString("uri")
 		trx, _ := cmd.Root().PersistentFlags().GetBool("trx")
-
-		wj := make(chan int, warehouses)
-		wr := make(chan int, warehouses)
-
-		ctx := context.Background()
+		debug, _ := cmd.Root().PersistentFlags().GetBool("debug")
+		user, password, tlsConfig := connection(cmd)
+		seed(cmd)
+		shard, _ := cmd.PersistentFlags().GetBool("shard")
+		shardZones, _ := cmd.PersistentFlags().GetBool("shard-zones")
 
 		if dbname == "" || uri == "" {
 			panic("empty")
 		}
 
-		for i := 1; i <= warehouses; i++ {
-			wj <- i
+		caps, ok := databases.Lookup(dbdriver)
+		if !ok {
+			panic("dbdriver not correct")
+		}
+
+		if trx && !caps.Transactions {
+			panic("trx not supported by " + dbdriver)
+		}
+
+		if shard && !caps.Shard {
+			panic("shard not supported by " + dbdriver)
+		}
+
+		if shardZones && !caps.Shard {
+			panic("shard-zones not supported by " + dbdriver)
 		}
 
 		c := tpcc.Configuration{
@@ -45,65 +59,82 @@ var prepareCmd = &cobra.Command{
 			WareHouses:     warehouses,
 			ScaleFactor:    scalefactor,
 			URI:            uri,
+			User:           user,
+			Password:       password,
+			TLS:            tlsConfig,
 			Transactions:   trx,
+			Shard:          shard,
+			ShardZones:     shardZones,
+			Debug:          debug,
+			DriverParams:   driverParams(cmd),
 		}
 
-		ddl, err := tpcc.NewWorker(&c, nil, nil, 0)
-		if err != nil {
-			panic(err)
-		}
+		load(&c)
+	},
+}
 
-		fmt.Println("Creating schema")
-		err = ddl.CreateSchema()
-		if err != nil {
-			panic(err)
-		}
-		fmt.Println("... done")
+// load creates the schema, loads the items and all warehouses with c.Threads workers and creates the indexes
+func load(c *tpcc.Configuration) {
+	wj := make(chan int, c.WareHouses)
+	wr := make(chan int, c.WareHouses)
 
-		if err != nil {
-			panic(err)
-		}
+	ctx := context.Background()
 
-		for i := 0; i < threads; i++ {
-			go func(i int) {
+	for i := 1; i <= c.WareHouses; i++ {
+		wj <- i
+	}
+	close(wj)
 
-				w, err := tpcc.NewWorker(&c, nil, nil, i)
-				if err != nil {
-					panic(err)
-				}
+	ddl, err := tpcc.NewWorker(c, nil, nil, 0)
+	if err != nil {
+		panic(err)
+	}
 
-				if i == 0 {
-					fmt.Println("Loading items")
-					w.LoadItems(ctx)
-				}
+	fmt.Println("Creating schema")
+	err = ddl.CreateSchema()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("... done")
 
-				for wId := range wj {
+	for i := 0; i < c.Threads; i++ {
+		go func(i int) {
 
-					fmt.Printf("Loading warehouse %d\n", wId)
-					err := w.LoadWarehouse(ctx, wId)
-					if err != nil {
-						panic(err)
-					}
-					wr <- wId
-				}
+			w, err := tpcc.NewWorker(c, nil, nil, i)
+			if err != nil {
+				panic(err)
+			}
 
-			}(i)
-		}
+			if i == 0 {
+				fmt.Println("Loading items")
+				w.LoadItems(ctx)
+			}
 
-		for i := 1; i <= warehouses; i++ {
-			<-wr
-		}
+			for wId := range wj {
 
-		fmt.Println("Creating indexes")
-		ddl.CreateIndexes()
+				fmt.Printf("Loading warehouse %d\n", wId)
+				err := w.LoadWarehouse(ctx, wId)
+				if err != nil {
+					panic(err)
+				}
+				wr <- wId
+			}
 
-		if err != nil {
-			panic(err)
-		}
+		}(i)
+	}
 
-		fmt.Println("... done")
+	for i := 1; i <= c.WareHouses; i++ {
+		<-wr
+	}
 
-	},
+	fmt.Println("Creating indexes")
+	err = ddl.CreateIndexes()
+
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("... done")
 }
 
 func init() {
@@ -112,6 +143,12 @@ func init() {
 	prepareCmd.PersistentFlags().Int("threads", 8, "Amount of threads that will be used when preparing. min(threads, warehouses) will be used at most")
 	prepareCmd.PersistentFlags().Int("warehouses", 10, "Number of warehouses to generate the data")
 	prepareCmd.PersistentFlags().Float64("scalefactor", 1, "Scale-factor")
+	prepareCmd.PersistentFlags().Bool("shard", false, "Shard collections on the warehouse id and pre-split one chunk per warehouse (mongodb via mongos only)")
+	prepareCmd.PersistentFlags().Bool("shard-zones", false, "With --shard, create one zone per shard and spread the warehouses evenly across them")
+	prepareCmd.PersistentFlags().Int("es-shards", 1, "Number of primary shards per index (elasticSearch only)")
+	prepareCmd.PersistentFlags().Int("es-replicas", 1, "Number of replicas per index, applied after loading (elasticSearch only)")
+	prepareCmd.PersistentFlags().String("es-refresh-interval", "1s", "index.refresh_interval applied after loading, -1 disables periodic refresh (elasticSearch only)")
+	prepareCmd.PersistentFlags().String("es-translog-durability", "request", "index.translog.durability applied after loading (request|async) (elasticSearch only)")
 
 	prepareCmd.Root().MarkFlagRequired("uri")
 	prepareCmd.Root().MarkFlagRequired("db")
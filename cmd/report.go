@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	htmlreport "github.com/Percona-Lab/go-tpcc/report"
+	"github.com/Percona-Lab/go-tpcc/stats"
+
+	"github.com/spf13/cobra"
+)
+
+// reportCmd renders the json output of a run as an HTML page
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Render the --report-format json output of a run as a self-contained HTML report",
+	Run: func(cmd *cobra.Command, args []string) {
+		in, _ := cmd.Flags().GetString("in")
+		out, _ := cmd.Flags().GetString("out")
+		title, _ := cmd.Flags().GetString("title")
+
+		if in == "" || out == "" {
+			panic("empty")
+		}
+
+		if title == "" {
+			title = "go-tpcc " + filepath.Base(in)
+		}
+
+		r, err := stats.ReadResult(in)
+		if err != nil {
+			panic(err)
+		}
+
+		f, err := os.Create(out)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+
+		err = htmlreport.HTML(f, r, title)
+		if err != nil {
+			panic(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().String("in", "", "json output of go-tpcc run")
+	reportCmd.Flags().String("out", "report.html", "HTML file to write")
+	reportCmd.Flags().String("title", "", "title of the report (default go-tpcc <in>)")
+}
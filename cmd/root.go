@@ -3,7 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/Percona-Lab/go-tpcc/databases"
+	"github.com/Percona-Lab/go-tpcc/helpers"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -31,8 +35,71 @@ func init() {
 	//rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.mongo-tpcc.yaml)")
 	rootCmd.PersistentFlags().String("uri", "", "DSN")
 	rootCmd.PersistentFlags().String("db", "", "database name to use")
-	rootCmd.PersistentFlags().String("dbdriver", "mysql", "db driver to use (mongodb|mysql)")
+	rootCmd.PersistentFlags().String("user", "", "user to authenticate as, overrides the one of --uri (default $"+userEnv+")")
+	rootCmd.PersistentFlags().String("password", "", "password of --user, visible in process listings, prefer --password-file (default $"+passwordEnv+")")
+	rootCmd.PersistentFlags().String("password-file", "", "file containing the password of --user")
+	rootCmd.PersistentFlags().String("tls-ca", "", "PEM file of the CA that signed the server certificate")
+	rootCmd.PersistentFlags().String("tls-cert", "", "PEM file of the client certificate, requires --tls-key")
+	rootCmd.PersistentFlags().String("tls-key", "", "PEM file of the client key, requires --tls-cert")
+	rootCmd.PersistentFlags().Bool("tls-skip-verify", false, "connect with TLS without verifying the server certificate. false by default")
+	rootCmd.PersistentFlags().String("dbdriver", "mysql", "db driver to use ("+strings.Join(databases.Drivers(), "|")+"), see go-tpcc drivers")
 	rootCmd.PersistentFlags().Bool("trx", false, "use trx?. false by default")
+	rootCmd.PersistentFlags().String("trx-mode", "manual", "how transactions are driven when --trx is set (manual|callback). callback uses the driver's WithTransaction")
+	rootCmd.PersistentFlags().Bool("aggregate", false, "use aggregation pipelines for SumOLAmount, GetStockCount and GetCustomerByName (mongodb only). false by default")
+	rootCmd.PersistentFlags().StringArray("driver-opt", nil, "setting of the driver as name=value, repeatable. The driver specific flags like --es-refresh are shorthands for it")
+	rootCmd.PersistentFlags().String("es-refresh", "true", "refresh policy for writes (true|wait_for|false) (elasticSearch only)")
+	rootCmd.PersistentFlags().Bool("es-occ", false, "fail New-Order if its district or stock changed since they were read (elasticSearch only). false by default")
+	rootCmd.PersistentFlags().Bool("sqlite-wal", true, "use write-ahead logging, --uri is the database file (sqlite only)")
+	rootCmd.PersistentFlags().String("sqlite-synchronous", "normal", "PRAGMA synchronous (off|normal|full|extra) (sqlite only)")
+	rootCmd.PersistentFlags().Int64("seed", 0, "seed of the random generator, 0 seeds it with the current time")
+	rootCmd.PersistentFlags().Bool("debug", false, "log every request sent to the database (elasticSearch only). false by default")
+	rootCmd.PersistentFlags().Bool("findandmodify", false, "increment the order id and update the stock of New-Order atomically with findAndModify (mongodb), UPDATE ... RETURNING (postgresql|sqlite) or in one call (memory|bbolt). false by default")
+}
+
+// driverFlags are the driver specific flags, they are passed to the driver as the parameter of the same name
+var driverFlags = []string{
+	"es-refresh", "es-occ", "es-shards", "es-replicas", "es-refresh-interval", "es-translog-durability",
+	"sqlite-wal", "sqlite-synchronous",
+}
+
+// driverParams returns the parameters of the driver from --driver-opt and the driver specific flags of cmd.
+// A flag given on the command line overrides --driver-opt, the default of a flag does not.
+func driverParams(cmd *cobra.Command) map[string]string {
+	opts, _ := cmd.Root().PersistentFlags().GetStringArray("driver-opt")
+
+	params := map[string]string{}
+	for _, opt := range opts {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			panic("driver-opt not correct")
+		}
+		params[kv[0]] = kv[1]
+	}
+
+	for _, name := range driverFlags {
+		f := cmd.Flags().Lookup(name)
+		if f == nil {
+			continue
+		}
+		if _, ok := params[name]; ok && !f.Changed {
+			continue
+		}
+		params[name] = f.Value.String()
+	}
+
+	return params
+}
+
+// seed seeds the random generator with --seed and returns the seed used
+func seed(cmd *cobra.Command) int64 {
+	seed, _ := cmd.Root().PersistentFlags().GetInt64("seed")
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	helpers.Seed(seed)
+
+	return seed
 }
 
 // initConfig reads in config file and ENV variables if set.
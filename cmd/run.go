@@ -2,12 +2,20 @@ package cmd
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math"
-	"sort"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Percona-Lab/go-tpcc/databases"
+	"github.com/Percona-Lab/go-tpcc/executor"
+	"github.com/Percona-Lab/go-tpcc/stats"
 	"github.com/Percona-Lab/go-tpcc/tpcc"
 
 	"github.com/spf13/cobra"
@@ -29,13 +37,71 @@ var runCmd = &cobra.Command{
 		trx, _ := cmd.Root().PersistentFlags().GetBool("trx")
 		rf_, _ := cmd.PersistentFlags().GetString("report-format")
 		perc, _ := cmd.PersistentFlags().GetInt("percentile")
+		histogramOut, _ := cmd.PersistentFlags().GetString("histogram-out")
+		metricsAddr, _ := cmd.PersistentFlags().GetString("metrics-addr")
+		controlAddr, _ := cmd.PersistentFlags().GetString("control-addr")
+		eventLogPath, _ := cmd.PersistentFlags().GetString("event-log")
+		eventLogFormat, _ := cmd.PersistentFlags().GetString("event-log-format")
 		percfail, _ := cmd.PersistentFlags().GetInt("percent-fail")
+		rate, _ := cmd.PersistentFlags().GetFloat64("rate")
+		arrival, _ := cmd.PersistentFlags().GetString("arrival")
+		profileFlag, _ := cmd.PersistentFlags().GetString("profile")
 		dbdriver, _ := cmd.Root().PersistentFlags().GetString("dbdriver")
+		findandmodify, _ := cmd.Root().PersistentFlags().GetBool("findandmodify")
+		trxmode, _ := cmd.Root().PersistentFlags().GetString("trx-mode")
+		aggregate, _ := cmd.Root().PersistentFlags().GetBool("aggregate")
+		debug, _ := cmd.Root().PersistentFlags().GetBool("debug")
+		user, password, tlsConfig := connection(cmd)
+		randSeed := seed(cmd)
 
 		if perc > 100 || perc < 0 {
 			panic("percentile not correct")
 		}
 
+		if rate < 0 || rate > tpcc.MaxRate {
+			panic("rate not correct")
+		}
+
+		if arrival != tpcc.ConstantArrival && arrival != tpcc.PoissonArrival {
+			panic("arrival not correct")
+		}
+
+		var profile tpcc.Profile
+		if profileFlag != "" {
+			var err error
+			profile, err = tpcc.ParseProfile(profileFlag)
+			if err != nil {
+				panic(err)
+			}
+			// the profile decides how long the run takes, whole seconds as reported
+			time = int(math.Ceil(profile.Duration().Seconds()))
+		}
+
+		if eventLogFormat != "ndjson" && eventLogFormat != "csv" {
+			panic("event-log-format not correct")
+		}
+
+		if trxmode != "manual" && trxmode != "callback" {
+			panic("trx-mode not correct")
+		}
+
+		caps, ok := databases.Lookup(dbdriver)
+		if !ok {
+			panic("dbdriver not correct")
+		}
+
+		if trx && !caps.Transactions {
+			panic("trx not supported by " + dbdriver)
+		}
+
+		if findandmodify && !caps.FindAndModify {
+			panic("findandmodify not supported by " + dbdriver)
+		}
+
+		if aggregate && !caps.Aggregate {
+			panic("aggregate not supported by " + dbdriver)
+		}
+
 		var rf OutputType
 		switch rf_ {
 		case "json":
@@ -54,34 +120,96 @@ var runCmd = &cobra.Command{
 		wg := &sync.WaitGroup{}
 		c := make(chan tpcc.Transaction, 1024)
 
-		for i := 0; i < threads; i++ {
-			wg.Add(1)
-			go func(i int) {
-
-				conf := tpcc.Configuration{
-					DBDriver:       dbdriver,
-					DBName:         dbname,
-					Threads:        threads,
-					WriteConcern:   0,
-					ReadConcern:    0,
-					ReportInterval: ri,
-					WareHouses:     warehouses,
-					ScaleFactor:    scalefactor,
-					URI:            uri,
-					Transactions:   trx,
-					PercentFail:    percfail,
-				}
+		base := tpcc.Configuration{
+			DBDriver:       dbdriver,
+			DBName:         dbname,
+			Threads:        threads,
+			WriteConcern:   0,
+			ReadConcern:    0,
+			ReportInterval: ri,
+			WareHouses:     warehouses,
+			ScaleFactor:    scalefactor,
+			URI:            uri,
+			User:           user,
+			Password:       password,
+			TLS:            tlsConfig,
+			Transactions:   trx,
+			PercentFail:    percfail,
+			FindAndModify:  findandmodify,
+			TrxMode:        trxmode,
+			Aggregate:      aggregate,
+			Debug:          debug,
+			DriverParams:   driverParams(cmd),
+		}
 
-				w, err := tpcc.NewWorker(&conf, wg, c, i)
-				if err != nil {
-					panic(err)
-				}
-				w.Execute(ctx)
-			}(i)
+		var metrics *stats.Metrics
+		if metricsAddr != "" {
+			metrics = stats.NewMetrics()
+			err := metrics.Serve(metricsAddr)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		var events *stats.EventLog
+		if eventLogPath != "" {
+			var err error
+			events, err = stats.NewEventLog(eventLogPath, eventLogFormat)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		// in-process drivers only live as long as the process, so the data is loaded right here
+		if caps.InProcess {
+			load(&base)
+		}
+
+		if rf == JSONOutput {
+			printJSON(header(&base, randSeed, stats.RunConfig{
+				DB:             dbname,
+				Warehouses:     warehouses,
+				ScaleFactor:    scalefactor,
+				Threads:        threads,
+				Time:           time,
+				ReportInterval: ri,
+				Percentile:     float64(perc),
+				PercentFail:    percfail,
+				Transactions:   trx,
+				TrxMode:        trxmode,
+				FindAndModify:  findandmodify,
+				Aggregate:      aggregate,
+				Rate:           rate,
+				Arrival:        arrival,
+				Profile:        profileFlag,
+			}))
+		}
+
+		metrics.SetPhase(stats.PhaseRun)
+
+		schedule := newSchedule(rate, arrival)
+		var workerControl *tpcc.Control
+		var ctl *control
+		if controlAddr != "" {
+			workerControl = tpcc.NewControl()
+		}
+		workers := newPool(ctx, &base, wg, c, schedule, workerControl, metrics)
+		if controlAddr != "" {
+			ctl = newControl(workers, schedule, profile, workerControl)
+			err := ctl.Serve(controlAddr)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		if profile != nil {
+			go workers.follow(ctx, profile, now())
+		} else {
+			workers.Resize(threads)
 		}
 
 		wg.Add(1)
-		go stats(cancel, c, wg, time, ri, rf, float64(perc))
+		go report(cancel, c, wg, time, ri, rf, float64(perc), histogramOut, metrics, events, schedule, profile, ctl)
 		wg.Wait()
 	},
 }
@@ -97,7 +225,15 @@ func init() {
 	runCmd.PersistentFlags().Int("percent-fail", 0, "How much % of New Order trxs should fail [0-100]")
 
 	runCmd.PersistentFlags().Float64("scalefactor", 1, "Scale-factor")
+	runCmd.PersistentFlags().Float64("rate", 0, "start this many transactions per second in total regardless of how fast they finish, at most threads at a time. 0 runs every thread as fast as it can")
+	runCmd.PersistentFlags().String("profile", "", "change the threads during the run in stages of threads:duration or linear ramps of from-to:duration, e.g. 8:60s,16:60s,32:60s or 1-64:10m. Replaces --threads and --time")
+	runCmd.PersistentFlags().String("arrival", "constant", "arrival process of --rate (constant|poisson)")
 	runCmd.PersistentFlags().String("report-format", "default", "default|json|csv")
+	runCmd.PersistentFlags().String("control-addr", "", "serve an HTTP API on <addr> to follow and change the run while it runs, e.g. localhost:9200")
+	runCmd.PersistentFlags().String("metrics-addr", "", "serve Prometheus metrics on http://<addr>/metrics during the run, e.g. :9100")
+	runCmd.PersistentFlags().String("event-log", "", "write one record per transaction to this file, gzip compressed if it ends in .gz")
+	runCmd.PersistentFlags().String("event-log-format", "ndjson", "format of --event-log (ndjson|csv)")
+	runCmd.PersistentFlags().String("histogram-out", "", "write the latency histograms of the whole run to this file, to merge them with other runs later")
 
 	rootCmd.MarkFlagRequired("uri")
 	rootCmd.MarkFlagRequired("db")
@@ -111,10 +247,18 @@ const (
 	JSONOutput
 )
 
-func stats(cancel context.CancelFunc, c chan tpcc.Transaction, wg *sync.WaitGroup, ttime int, ri int, output OutputType, percentile float64) {
+// report prints the throughput and the latency percentile of every interval and the latency distribution of the
+// whole run at the end. Every transaction is recorded in metrics and written to events too, if they are not nil.
+// The backlog of schedule is reported in an open-loop run and the stage of profile and its threads if it is not nil.
+// ctl gets every interval and can end the run before ttime.
+func report(cancel context.CancelFunc, c chan tpcc.Transaction, wg *sync.WaitGroup, ttime int, ri int, output OutputType, percentile float64, histogramOut string, metrics *stats.Metrics, events *stats.EventLog, schedule *tpcc.Schedule, profile tpcc.Profile, ctl *control) {
 	defer wg.Done()
+	start := time.Now()
 	ticker := time.NewTicker(time.Duration(ri) * time.Second)
 	timeout := time.After(time.Duration(ttime)*time.Second + 99*time.Millisecond)
+	// a run stopped early reports its actual duration instead of the finished intervals
+	stop := ctl.Stopped()
+	stopped := false
 	i := ri
 	type Transactions struct {
 		StockLevelCnt  int
@@ -127,17 +271,50 @@ func stats(cancel context.CancelFunc, c chan tpcc.Transaction, wg *sync.WaitGrou
 
 	globalStats := make(map[int]*Transactions)
 	batchStats := make(map[int]*Transactions)
-	latencies := make(map[tpcc.TransactionType][]float64)
+	// latencies of the current interval, merged into total when it is reported
+	latencies := stats.Set{}
+	breakdown := stats.NewBreakdown()
+	total := stats.Set{}
 
-	if output == CSVOutput {
-		fmt.Println("Time,TPS,StockLevel,StockLevelLatency,Delivery,DeliveryLatency,OrderStatus,OrderStatusLatency,Payment,PaymentLatency,NewOrder,NewOrderLatency,Failed")
-	}
+	printIntervalHeader(output, schedule != nil, profile != nil)
 
 	for {
 		select {
+		case <-stop:
+			stop = nil
+			stopped = true
+			timeout = closed()
 		case <-timeout:
+			backlog := schedule.Backlog(time.Now())
 			cancel()
 			time.Sleep(1 * time.Second)
+			metrics.SetPhase(stats.PhaseDone)
+
+			if events != nil {
+				err := events.Close()
+				if err != nil {
+					fmt.Println(err)
+				}
+			}
+
+			total.Merge(latencies)
+
+			failed := 0
+			for _, value := range globalStats {
+				failed += value.Failed
+			}
+			duration := float64(i - ri)
+			if stopped {
+				duration = time.Since(start).Seconds()
+			}
+			summary(total, breakdown, output, duration, failed, schedule != nil, backlog)
+
+			if histogramOut != "" {
+				err := total.WriteFile(histogramOut)
+				if err != nil {
+					fmt.Println(err)
+				}
+			}
 			return
 		case v := <-c:
 
@@ -188,7 +365,29 @@ func stats(cancel context.CancelFunc, c chan tpcc.Transaction, wg *sync.WaitGrou
 				globalStats[v.ThreadId].NewOrderCnt++
 			}
 
-			latencies[v.Type] = append(latencies[v.Type], v.Time)
+			latencies.Record(v.Type.String(), v.Time)
+			breakdown.Add(v.Type.String(), v.Outcome.String(), v.ErrorClass, v.Error)
+			metrics.Observe(v.Type.String(), v.Outcome.String(), v.Retries, v.Time)
+
+			if events != nil {
+				err := events.Write(stats.Event{
+					Start:      v.Start,
+					End:        v.End,
+					Type:       v.Type.String(),
+					Worker:     v.ThreadId,
+					Warehouse:  v.WarehouseId,
+					District:   v.DistrictId,
+					Retries:    v.Retries,
+					Outcome:    v.Outcome.String(),
+					ErrorClass: v.ErrorClass,
+				})
+				if err != nil {
+					// keep running, the log is incomplete anyway
+					fmt.Println(err)
+					events.Close()
+					events = nil
+				}
+			}
 
 		case <-ticker.C:
 			sCnt := 0
@@ -207,51 +406,288 @@ func stats(cancel context.CancelFunc, c chan tpcc.Transaction, wg *sync.WaitGrou
 				failed += value.Failed
 			}
 			batchStats = make(map[int]*Transactions)
-			var format string
-			switch output {
-			case CSVOutput:
-				format = "%d,%.2f,%d,%.2f,%d,%.2f,%d,%.2f,%d,%.2f,%d,%.2f,%d\n"
-			case JSONOutput:
-				format = "{\"time\": %d, \"tps\": %.2f, \"StockLevel\": { \"Trx\": %d, \"LatencyPercentile\": %.2f}, " +
-					"\"Delivery\": { \"Trx\": %d, \"LatencyPercentile\": %.2f}, " +
-					"\"OrderStatus\": { \"Trx\": %d, \"LatencyPercentile\":%.2f}, " +
-					"\"Payment\": { \"Trx\": %d, \"LatencyPercentile\": %.2f}, " +
-					"\"NewOrder\": { \"Trx\": %d, \"LatencyPercentile\": %.2f}," +
-					"\"Failed\": %d}\n"
-			default:
-				format = "[ %ds ] TPS: %.2f StockLevel: %d (%.2f ms) Delivery: %d (%.2f ms) OrderStatus: %d (%.2f ms) Payment: %d (%.2f ms) NewOrder: %d (%.2f ms) Failed: %d\n"
+			backlog := schedule.Backlog(time.Now())
+			// a ramp changes the threads during the interval, they are taken from its middle
+			stage, threads := 0, 0
+			if profile != nil {
+				stage, threads = profile.At(time.Duration((float64(i) - float64(ri)/2) * float64(time.Second)))
+			}
+			tps := float64(sCnt+dCnt+oCnt+pCnt+nCnt) / float64(ri)
+
+			counts := []int{sCnt, dCnt, oCnt, pCnt, nCnt}
+			interval := stats.Interval{
+				Type:         stats.IntervalRecord,
+				Time:         float64(i),
+				TPS:          tps,
+				Failed:       int64(failed),
+				Transactions: map[string]stats.IntervalTransactions{},
+			}
+			if schedule != nil {
+				interval.Backlog = &backlog
 			}
+			if profile != nil {
+				interval.Stage = stage
+				interval.Threads = threads
+			}
+			for n, t := range tpcc.TransactionTypes {
+				interval.Transactions[t.String()] = stats.IntervalTransactions{
+					Count:   int64(counts[n]),
+					Latency: latencies.Get(t.String()).Percentile(percentile),
+				}
+			}
+
+			printInterval(output, interval)
 
-			fmt.Printf(
-				format,
-				i,
-				float64(sCnt+dCnt+oCnt+pCnt+nCnt)/float64(ri),
-				sCnt,
-				float64(perc(latencies[tpcc.StockLevelTrx], percentile)),
-				dCnt,
-				float64(perc(latencies[tpcc.DeliveryTrx], percentile)),
-				oCnt,
-				float64(perc(latencies[tpcc.OrderStatusTrx], percentile)),
-				pCnt,
-				float64(perc(latencies[tpcc.PaymentTrx], percentile)),
-				nCnt,
-				float64(perc(latencies[tpcc.NewOrderTrx], percentile)),
-				failed,
-			)
+			total.Merge(latencies)
+			latencies.Reset()
 
+			if ctl != nil {
+				failedTotal := 0
+				for _, value := range globalStats {
+					failedTotal += value.Failed
+				}
+				ctl.update(interval, runSummary(total, breakdown, float64(i), failedTotal, schedule != nil, backlog))
+			}
 			i += ri
-			latencies = make(map[tpcc.TransactionType][]float64)
 		default:
 		}
 	}
 }
 
-func perc(a []float64, p float64) float64 {
-	p = p / 100
-	if len(a) == 0 {
-		return 0
+// printIntervalHeader prints the CSV header of the intervals, with the backlog of an open-loop run and the stage and
+// threads of a run with a profile
+func printIntervalHeader(output OutputType, openLoop bool, profiled bool) {
+	if output != CSVOutput {
+		return
+	}
+
+	header := "Time,TPS,StockLevel,StockLevelLatency,Delivery,DeliveryLatency,OrderStatus,OrderStatusLatency,Payment,PaymentLatency,NewOrder,NewOrderLatency,Failed"
+	if openLoop {
+		header += ",Backlog"
+	}
+	if profiled {
+		header += ",Stage,Threads"
+	}
+	fmt.Println(header)
+}
+
+// printInterval prints the report of one interval in the output format
+func printInterval(output OutputType, interval stats.Interval) {
+	if output == JSONOutput {
+		printJSON(interval)
+		return
+	}
+
+	var format string
+	switch output {
+	case CSVOutput:
+		format = "%d,%.2f,%d,%.2f,%d,%.2f,%d,%.2f,%d,%.2f,%d,%.2f,%d\n"
+	default:
+		format = "[ %ds ] TPS: %.2f StockLevel: %d (%.2f ms) Delivery: %d (%.2f ms) OrderStatus: %d (%.2f ms) Payment: %d (%.2f ms) NewOrder: %d (%.2f ms) Failed: %d\n"
+	}
+
+	args := []interface{}{int(interval.Time), interval.TPS}
+	for _, t := range tpcc.TransactionTypes {
+		args = append(args, interval.Transactions[t.String()].Count, interval.Transactions[t.String()].Latency)
+	}
+	args = append(args, interval.Failed)
+
+	format = strings.TrimSuffix(format, "\n")
+	if interval.Backlog != nil {
+		if output == CSVOutput {
+			format += ",%d"
+		} else {
+			format += " Backlog: %d"
+		}
+		args = append(args, *interval.Backlog)
+	}
+	if interval.Stage != 0 {
+		if output == CSVOutput {
+			format += ",%d,%d"
+		} else {
+			format += " Stage: %d Threads: %d"
+		}
+		args = append(args, interval.Stage, interval.Threads)
+	}
+	format += "\n"
+
+	fmt.Printf(format, args...)
+}
+
+// summary prints the latency distribution and the outcomes of every transaction type and the errors by class,
+// duration is the reported time in seconds. backlog is printed if the run was open-loop.
+func summary(total stats.Set, breakdown *stats.Breakdown, output OutputType, duration float64, failed int, openLoop bool, backlog int) {
+	if output == JSONOutput {
+		printJSON(runSummary(total, breakdown, duration, failed, openLoop, backlog))
+		return
+	}
+
+	if output == CSVOutput {
+		fmt.Println("Type,Count,Mean,P50,P90,P95,P99,P99.9,Max")
+	} else {
+		fmt.Println("Latency (ms):")
+	}
+
+	for _, t := range tpcc.TransactionTypes {
+		s := total.Get(t.String()).Summary()
+
+		if output == CSVOutput {
+			fmt.Printf("%s,%d,%.2f,%.2f,%.2f,%.2f,%.2f,%.2f,%.2f\n", t, s.Count, s.Mean, s.P50, s.P90, s.P95, s.P99, s.P999, s.Max)
+		} else {
+			fmt.Printf("  %-12s count: %d mean: %.2f p50: %.2f p90: %.2f p95: %.2f p99: %.2f p99.9: %.2f max: %.2f\n",
+				t, s.Count, s.Mean, s.P50, s.P90, s.P95, s.P99, s.P999, s.Max)
+		}
+	}
+
+	if output == CSVOutput {
+		var header []string
+		for _, o := range executor.Outcomes {
+			header = append(header, o.String())
+		}
+		fmt.Printf("\nType,%s\n", strings.Join(header, ","))
+	} else {
+		fmt.Println("Outcomes:")
+	}
+
+	for _, t := range tpcc.TransactionTypes {
+		var counts []string
+		for _, o := range executor.Outcomes {
+			n := breakdown.Outcomes[t.String()][o.String()]
+			if output == CSVOutput {
+				counts = append(counts, fmt.Sprint(n))
+			} else {
+				counts = append(counts, fmt.Sprintf("%s: %d", o, n))
+			}
+		}
+
+		if output == CSVOutput {
+			fmt.Printf("%s,%s\n", t, strings.Join(counts, ","))
+		} else {
+			fmt.Printf("  %-12s %s\n", t, strings.Join(counts, " "))
+		}
+	}
+
+	if openLoop {
+		if output == CSVOutput {
+			fmt.Printf("\nBacklog\n%d\n", backlog)
+		} else {
+			fmt.Printf("Backlog: %d transactions behind schedule at the end\n", backlog)
+		}
+	}
+
+	errors := breakdown.Errors()
+	if len(errors) == 0 {
+		return
+	}
+
+	if output == CSVOutput {
+		fmt.Println()
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"Type", "Class", "Count", "Sample"})
+		for _, e := range errors {
+			w.Write([]string{e.Type, e.Class, fmt.Sprint(e.Count), e.Sample})
+		}
+		w.Flush()
+		return
+	}
+
+	fmt.Println("Errors:")
+	for _, e := range errors {
+		fmt.Printf("  %-12s %-10s %d  %s\n", e.Type, e.Class, e.Count, e.Sample)
+	}
+}
+
+// runSummary is the summary record of the json output, see summary for the arguments
+func runSummary(total stats.Set, breakdown *stats.Breakdown, duration float64, failed int, openLoop bool, backlog int) stats.RunSummary {
+	s := stats.RunSummary{
+		Type:         stats.SummaryRecord,
+		Duration:     duration,
+		Failed:       int64(failed),
+		Transactions: map[string]stats.Summary{},
+		Outcomes:     breakdown.Outcomes,
+		Errors:       breakdown.Errors(),
+	}
+	if openLoop {
+		s.Backlog = &backlog
+	}
+	for _, t := range tpcc.TransactionTypes {
+		s.Transactions[t.String()] = total.Get(t.String()).Summary()
+	}
+	if duration > 0 {
+		s.TpmC = float64(s.Transactions[tpcc.NewOrderTrx.String()].Count) / duration * 60
+	}
+
+	return s
+}
+
+// header returns the first record of the json output, it connects once more to ask for the server version
+func header(base *tpcc.Configuration, seed int64, config stats.RunConfig) stats.Header {
+	h := stats.Header{
+		Type:    stats.HeaderRecord,
+		Version: stats.SchemaVersion,
+		RunID:   runID(),
+		Start:   time.Now(),
+		Seed:    seed,
+		Driver:  base.DBDriver,
+		Config:  config,
+	}
+
+	w, err := tpcc.NewWorker(base, nil, nil, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	h.ServerVersion, err = w.ServerVersion(context.Background())
+	if err != nil {
+		panic(err)
+	}
+
+	return h
+}
+
+// now is time.Now for Run, which shadows the time package
+func now() time.Time {
+	return time.Now()
+}
+
+// closed returns a closed channel, to take the branch of a select that waits for it right away
+func closed() <-chan time.Time {
+	c := make(chan time.Time)
+	close(c)
+	return c
+}
+
+// newSchedule returns the schedule of an open-loop run starting now, nil if rate is 0
+func newSchedule(rate float64, arrival string) *tpcc.Schedule {
+	if rate == 0 {
+		return nil
+	}
+
+	s, err := tpcc.NewSchedule(rate, arrival, time.Now())
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// runID returns a random identifier of the run
+func runID() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
 	}
-	sort.Float64s(a)
-	idx := int(math.Round(float64(len(a)) * p))
-	return a[idx-1]
+
+	return hex.EncodeToString(b)
+}
+
+// printJSON prints one record of the json output per line
+func printJSON(record interface{}) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(string(b))
 }
@@ -4,10 +4,6 @@ import (
 	"context"
 	"time"
 
-	"github.com/Percona-Lab/go-tpcc/databases/elasticsearch"
-	"github.com/Percona-Lab/go-tpcc/databases/mongodb"
-	"github.com/Percona-Lab/go-tpcc/databases/mysql"
-	"github.com/Percona-Lab/go-tpcc/databases/postgresql"
 	"github.com/Percona-Lab/go-tpcc/tpcc/models"
 )
 
@@ -15,6 +11,7 @@ type Database interface {
 	StartTrx() error
 	CommitTrx(ctx context.Context) error
 	RollbackTrx(ctx context.Context) error
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 	CreateSchema() error
 	CreateIndexes() error
 	InsertOne(ctx context.Context, ableName string, d interface{}) error
@@ -43,29 +40,12 @@ type Database interface {
 	CreateOrder(ctx context.Context, orderId int, customerId int, warehouseId int, districtId int, oCarrierId int, oOlCnt int, allLocal int, orderEntryDate time.Time, orderLine []models.OrderLine) error
 	GetItems(ctx context.Context, itemIds []int) (*[]models.Item, error)
 	UpdateStock(ctx context.Context, stockId int, warehouseId int, quantity int, ytd int, ordercnt int, remotecnt int) error
+	IncrementDistrictOrderIdAndGet(ctx context.Context, warehouseId int, districtId int) (*models.District, error)
+	UpdateStockAndGet(ctx context.Context, districtId int, stockId int, warehouseId int, quantity int, remote bool) (*models.Stock, error)
 	GetStockInfo(ctx context.Context, districtId int, iIds []int, iWids []int, allLocal int) (*[]models.Stock, error)
 }
 
-func NewDatabase(driver, uri, dbname, username, password string, transactions bool, findandmodify bool) (Database, error) {
-	var d Database
-	var err error
-
-	switch driver {
-	case "mongodb":
-		d, err = mongodb.NewMongoDb(uri, dbname, transactions, findandmodify)
-	case "mysql":
-		d, err = mysql.NewMySQL(uri, dbname, transactions)
-	case "postgresql":
-		d, err = postgresql.NewPostgreSQL(uri, dbname, transactions)
-	case "elasticSearch":
-		d, err = elasticsearch.NewElasticSearch(uri, findandmodify)
-	default:
-		panic("Unknown database driver")
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	return d, nil
+// Versioner is implemented by the drivers that can tell the version of the database server
+type Versioner interface {
+	ServerVersion(ctx context.Context) (string, error)
 }
@@ -3,13 +3,16 @@ package elasticsearch
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
-	"reflect"
-	"strings"
+	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
+	"github.com/Percona-Lab/go-tpcc/databases"
 	types "github.com/Percona-Lab/go-tpcc/databases/elasticsearch/models"
 	"github.com/Percona-Lab/go-tpcc/tpcc/models"
 	"github.com/elastic/go-elasticsearch/v7"
@@ -18,16 +21,83 @@ import (
 )
 
 type ElasticSearch struct {
-	Client *elasticsearch.Client
-	lock   bool
+	Client   *elasticsearch.Client
+	lock     bool
+	debug    bool
+	refresh  string
+	settings IndexSettings
+	// read are the versions of the documents read by the current New-Order by index/id, only kept with lock
+	read map[string]version
+}
+
+// version is the _seq_no/_primary_term a document had when it was read
+type version struct {
+	seqNo       int
+	primaryTerm int
+}
+
+func init() {
+	databases.Register("elasticSearch", func(o databases.Options) (databases.Database, error) {
+		occ, err := o.BoolParam("es-occ", false)
+		if err != nil {
+			return nil, err
+		}
+
+		refresh := o.Param("es-refresh", "true")
+		if refresh != "true" && refresh != "wait_for" && refresh != "false" {
+			return nil, fmt.Errorf("driver parameter es-refresh: %q is not true, wait_for or false", refresh)
+		}
+
+		settings := IndexSettings{
+			RefreshInterval:    o.Param("es-refresh-interval", "1s"),
+			TranslogDurability: o.Param("es-translog-durability", "request"),
+		}
+		if settings.TranslogDurability != "request" && settings.TranslogDurability != "async" {
+			return nil, fmt.Errorf("driver parameter es-translog-durability: %q is not request or async", settings.TranslogDurability)
+		}
+
+		settings.Shards, err = o.IntParam("es-shards", 1)
+		if err != nil {
+			return nil, err
+		}
+		settings.Replicas, err = o.IntParam("es-replicas", 1)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewElasticSearch(o.URI, o.Username, o.Password, o.TLS, occ, o.Debug, refresh, settings)
+	}, databases.Capabilities{
+		Description: "Elasticsearch 7, documents are indexed by primary key",
+	})
 }
 
 // connect elasticsearch
-func NewElasticSearch(uri string, lock bool) (*ElasticSearch, error) {
+// with lock the district and stock updates of New-Order fail on a concurrent change, see update
+// refresh is the refresh policy (true|wait_for|false) used on writes
+// username and password are sent with basic authentication, tlsConfig is used for https if not nil
+func NewElasticSearch(uri string, username string, password string, tlsConfig *tls.Config, lock bool, debug bool, refresh string, settings IndexSettings) (*ElasticSearch, error) {
+	if username != "" {
+		// the client prefers the credentials of the url over the configured ones
+		u, err := url.Parse(uri)
+		if err != nil {
+			return nil, err
+		}
+		u.User = nil
+		uri = u.String()
+	}
+
 	cfg := elasticsearch.Config{
 		Addresses: []string{
 			uri,
 		},
+		Username: username,
+		Password: password,
+	}
+
+	if tlsConfig != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = tlsConfig
+		cfg.Transport = t
 	}
 	es, err := elasticsearch.NewClient(cfg)
 
@@ -35,20 +105,52 @@ func NewElasticSearch(uri string, lock bool) (*ElasticSearch, error) {
 		return nil, err
 	}
 
-	_, errIn := es.Info()
-	if errIn != nil {
-		log.Fatalf("Error getting response: %s", errIn)
-		return nil, errIn
+	res, err := es.Info()
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, newError(res, "", "")
 	}
 
 	return &ElasticSearch{
-		Client: es,
-		lock:   lock,
+		Client:   es,
+		lock:     lock,
+		debug:    debug,
+		refresh:  refresh,
+		settings: settings,
+		read:     map[string]version{},
 	}, nil
 }
 
-func (db *ElasticSearch) CreateSchema() error {
-	return nil
+func (db *ElasticSearch) ServerVersion(ctx context.Context) (string, error) {
+	res, err := db.Client.Info(db.Client.Info.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return "", newError(res, "", "")
+	}
+
+	var info struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&info)
+
+	return info.Version.Number, err
+}
+
+// debugf logs per-request details when the driver runs with debug enabled
+func (db *ElasticSearch) debugf(format string, v ...interface{}) {
+	if db.debug {
+		log.Printf(format, v...)
+	}
 }
 
 // transaction 은 es에서는 version update 변수로 작동하므로 pass
@@ -66,87 +168,40 @@ func (db *ElasticSearch) RollbackTrx(ctx context.Context) error {
 	return nil
 }
 
-// there is no need about indexing on elasticsearch
-
-func (db *ElasticSearch) CreateIndexes() error {
-	var q map[string]interface{}
-	var dt []map[string]interface{}
-	var ol map[string]interface{}
-	var buf bytes.Buffer
-
-	ol = map[string]interface{}{
-		"path_match": "ORDER_LINE",
-		"mapping": map[string]interface{}{
-			"type": "nested",
-		},
-	}
-
-	dt[0] = map[string]interface{}{
-		"ORDER_LINE": ol,
-	}
-
-	q = map[string]interface{}{
-		"dynamic":           "false",
-		"dynamic_templates": dt,
-	}
-	if err := json.NewEncoder(&buf).Encode(q); err != nil {
-		log.Fatalf("Error encoding query: %s", err)
-	}
-
-	r := bytes.NewReader(buf.Bytes())
-
-	req := esapi.IndicesCreateRequest{
-		Index: "ORDERS",
-		Body:  r,
-	}
-
-	res, err := req.Do(context.Background(), db.Client)
-	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		log.Printf("[%s] Error indexing document", res.Status())
-	} else {
-		var r types.ResponseES
-		if errJs := json.NewDecoder(res.Body).Decode(&r); errJs != nil {
-			log.Printf("Error parsing the response body: %s", errJs)
-		}
-	}
-
-	return nil
+func (db *ElasticSearch) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
 }
 
 func (db *ElasticSearch) InsertOne(ctx context.Context, tableName string, d interface{}) (err error) {
 	// request indexing
 	dataJSON, err := json.Marshal(d)
-	js := string(dataJSON)
+	if err != nil {
+		return err
+	}
 
 	req := esapi.IndexRequest{
-		Index:   tableName,
-		Body:    strings.NewReader(js),
-		Refresh: "true",
+		Index:      indexName(tableName),
+		DocumentID: documentID(d),
+		Body:       bytes.NewReader(dataJSON),
+		Refresh:    db.refresh,
 	}
 
 	res, err := req.Do(ctx, db.Client)
-
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return err
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		log.Printf("[%s] Error indexing document", res.Status())
-	} else {
-		var r types.ResponseES
-		if errJs := json.NewDecoder(res.Body).Decode(&r); errJs != nil {
-			log.Printf("Error parsing the response body: %s", errJs)
-		} else {
-			log.Printf("[%s] version=%d ID=%s Index=%s", res.Status(), r.Version, r.ID, r.Index)
-		}
+		return newError(res, tableName, documentID(d))
 	}
 
+	var r types.ResponseES
+	if errJs := json.NewDecoder(res.Body).Decode(&r); errJs != nil {
+		return errJs
+	}
+	db.debugf("[%s] version=%d ID=%s Index=%s", res.Status(), r.Version, r.ID, r.Index)
+
 	return nil
 }
 
@@ -155,445 +210,380 @@ func (db *ElasticSearch) InsertBatch(ctx context.Context, tableName string, d []
 	retryOnConflit := new(int)
 	*retryOnConflit = 3
 
+	// the first failed item, reported once the indexer is flushed
+	var mu sync.Mutex
+	var failure error
+
 	// request indexing
-	indexer, _ := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-		Index:  tableName,
+	indexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Index:  indexName(tableName),
 		Client: db.Client,
 	})
+	if err != nil {
+		return err
+	}
 
 	for _, a := range d {
 		dataJSON, err := json.Marshal(a)
 		if err != nil {
-			log.Fatalf("cannot encode bulk %s", err)
+			return err
 		}
 
 		err = indexer.Add(
-			context.Background(),
+			ctx,
 			esutil.BulkIndexerItem{
 				// Action field configures the operation to perform (index, create, delete, update)
 				Action: "index",
 
-				// DocumentID is the (optional) document ID
+				// DocumentID is empty for HISTORY, which lets Elasticsearch generate one
+				DocumentID: documentID(a),
 
 				// Body is an `io.Reader` with the payload
 				Body: bytes.NewReader(dataJSON),
 
 				RetryOnConflict: retryOnConflit,
 
-				// OnSuccess is called for each successful operation
-				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
-					// SOMETHING
-				},
-
 				// OnFailure is called for each failed operation
 				OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
-					if err != nil {
-						log.Printf("ERROR: %s", err)
-					} else {
-						log.Printf("ERROR: %s: %s", res.Error.Type, res.Error.Reason)
+					if err == nil {
+						err = &Error{
+							StatusCode: res.Status,
+							Type:       res.Error.Type,
+							Reason:     res.Error.Reason,
+							Index:      tableName,
+							ID:         res.DocumentID,
+						}
 					}
+					db.debugf("bulk indexing into %s failed: %s", tableName, err)
+
+					mu.Lock()
+					if failure == nil {
+						failure = err
+					}
+					mu.Unlock()
 				},
 			},
 		)
 		if err != nil {
-			log.Fatalf("Unexpected error: %s", err)
+			return err
 		}
 	}
 
 	// Close the indexer
 	//
-	if err := indexer.Close(context.Background()); err != nil {
-		log.Fatalf("Unexpected error: %s", err)
+	if err := indexer.Close(ctx); err != nil {
+		return err
+	}
+
+	if failure != nil {
+		return fmt.Errorf("%d of %d documents failed to index: %w", indexer.Stats().NumFailed, len(d), failure)
 	}
 
 	return nil
 }
 
-// Get District using warehouseId and districtId and return pointer to models.District or error instead.
 func (db *ElasticSearch) IncrementDistrictOrderId(ctx context.Context, warehouseId int, districtId int) error {
-	var doc bytes.Buffer
-	documentUp := map[string]interface{}{
-		"scripts": "ctx._source.D_NEXT_O_ID += 1",
+	script := map[string]interface{}{
+		"source": "ctx._source.D_NEXT_O_ID += 1",
 	}
 
-	if err := json.NewEncoder(&doc).Encode(documentUp); err != nil {
-		log.Fatalf("Error encoding query: %s", err)
-	}
+	return db.update(ctx, "DISTRICT", key(warehouseId, districtId), script, true)
+}
 
-	m := types.Must{}
-	m[0] = map[string]map[string]interface{}{
-		"match": {"D_ID": districtId},
+// update runs script on the document id of index. If checked and lock are set the document is
+// written only if it did not change since the caller read it with GetDistrict or GetStockInfo,
+// a concurrent change is returned as an *Error reporting Conflict(). The version written is kept,
+// so a document updated twice, e.g. an item ordered twice by a New-Order, is checked again.
+// Otherwise Elasticsearch retries the script itself on a conflict.
+func (db *ElasticSearch) update(ctx context.Context, index string, id string, script map[string]interface{}, checked bool) error {
+	var doc bytes.Buffer
+	if err := json.NewEncoder(&doc).Encode(map[string]interface{}{"script": script}); err != nil {
+		return err
 	}
 
-	m[1] = map[string]map[string]interface{}{
-		"match": {"D_W_ID": warehouseId},
+	req := esapi.UpdateRequest{
+		Index:      indexName(index),
+		DocumentID: id,
+		Body:       &doc,
+		Refresh:    db.refresh,
 	}
 
-	var q types.BoolMustQuery
-
-	q.Query.Bool.Must = m
-
-	//!TODO: check version
-
-	indexes := [1]string{"DISTRICT"}
+	if checked && db.lock {
+		v, ok := db.read[index+"/"+id]
+		if !ok {
+			return fmt.Errorf("%s/%s was not read before the update", index, id)
+		}
 
-	refresh := new(bool)
-	*refresh = true
+		req.IfSeqNo = &v.seqNo
+		req.IfPrimaryTerm = &v.primaryTerm
+	} else {
+		retryOnConflict := 3
+		req.RetryOnConflict = &retryOnConflict
+	}
 
-	qString, err := json.Marshal(q)
+	res, err := req.Do(ctx, db.Client)
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
 
-	req := esapi.UpdateByQueryRequest{
-		Index:   indexes[:],
-		Body:    &doc,
-		Refresh: refresh,
-		Pretty:  true,
-		Query:   string(qString),
+	if res.IsError() {
+		return newError(res, index, id)
 	}
 
-	res, err := req.Do(ctx, db.Client)
-	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+	var r types.ResponseES
+	if errJs := json.NewDecoder(res.Body).Decode(&r); errJs != nil {
+		return errJs
 	}
+	db.debugf("[%s] %s/%s %s; version=%d", res.Status(), index, id, r.Result, r.Version)
 
-	defer res.Body.Close()
-
-	if res.IsError() {
-		log.Printf("[%s] Error delete document", res.Status())
-	} else {
-		var r map[string]interface{}
-		if errJs := json.NewDecoder(res.Body).Decode(&r); errJs != nil {
-			log.Printf("Error parsing the response body: %s", errJs)
-		} else {
-			log.Printf("[%s] %s; version=%d", res.Status(), r["result"], int(r["_version"].(float64)))
-		}
+	if checked && db.lock {
+		db.read[index+"/"+id] = version{seqNo: int(r.SeqNo), primaryTerm: int(r.PrimaryTerm)}
 	}
 
 	return nil
 }
 
-// It also deletes new order, as ElasticSearch can do that lock is set to 0
-func (db *ElasticSearch) CheckNewOrder(ctx context.Context, warehouseId int, districtId int) (*models.NewOrder, *string, error) {
-	m := types.Must{}
-	m[0] = map[string]map[string]interface{}{
-		"match": {"NO_D_ID": districtId},
+// remember keeps the versions of docs of index for the checked updates, if lock is set
+func (db *ElasticSearch) remember(index string, docs ...types.GetResponseES) {
+	if !db.lock {
+		return
 	}
 
-	m[1] = map[string]map[string]interface{}{
-		"match": {"NO_W_ID": warehouseId},
+	for _, doc := range docs {
+		db.read[index+"/"+doc.ID] = version{seqNo: int(doc.SeqNo), primaryTerm: int(doc.PrimaryTerm)}
 	}
+}
 
-	var q types.BoolMustQuery
-
-	q.Query.Bool.Must = m
-
-	//!TODO: check version
-
-	indexes := [1]string{"NEW_ORDER"}
+// get fetches the document id of index and decodes its source into r, r may be nil
+// when only the _seq_no/_primary_term are needed
+func (db *ElasticSearch) get(ctx context.Context, index string, id string, r interface{}) (*types.GetResponseES, error) {
+	req := esapi.GetRequest{
+		Index:      indexName(index),
+		DocumentID: id,
+	}
 
-	qString, err := json.Marshal(q)
+	res, err := req.Do(ctx, db.Client)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	var NewOrder models.NewOrder
-
-	for {
-		req := esapi.SearchRequest{
-			Index: indexes[:],
-			Query: string(qString),
-		}
-
-		res, err := req.Do(ctx, db.Client)
-		if err != nil {
-			continue
-		}
-
-		var NOID string
-
-		defer res.Body.Close()
-
-		if res.IsError() {
-			continue
-		}
-
-		var r types.SearchResponseESNOrder
-		if errJs := json.NewDecoder(res.Body).Decode(&r); errJs != nil {
-			continue
-		}
-
-		for _, hit := range r.Hits.Hits {
-			log.Printf(" * ID=%s", hit.ID)
-			NewOrder = hit.Source
-			NOID = hit.ID
-			// only one
-			break
-		}
-
-		v := reflect.ValueOf(NewOrder)
-		// check get NEW_ORDER
-		if !v.IsZero() {
-			break
-		}
+	defer res.Body.Close()
 
-		return &NewOrder, &NOID, nil
+	if res.IsError() {
+		return nil, newError(res, index, id)
 	}
-	return nil, nil, nil
-}
-
-// It also deletes new order, as ElasticSearch can do that lock is set to 0
-func (db *ElasticSearch) GetNewOrder(ctx context.Context, _ID int, _ int) (*models.NewOrder, error) {
-
-	if db.lock {
-		// check new order exist. if not exist, still locked
-		var NewOrder models.NewOrder
-		var NOID string
-
-		for {
-			var buf bytes.Buffer
-			query := map[string]interface{}{
-				"query": map[string]interface{}{
-					"match": map[string]interface{}{
-						"_id": _ID,
-					},
-				},
-			}
-			if err := json.NewEncoder(&buf).Encode(query); err != nil {
-				log.Fatalf("Error encoding query: %s", err)
-			}
-			indexes := [1]string{"NEW_ORDER"}
-
-			req := esapi.SearchRequest{
-				Index: indexes[:],
-				Query: buf.String(),
-			}
-
-			res, err := req.Do(ctx, db.Client)
-
-			if err != nil {
-				log.Fatalf("Error getting response: %s", err)
-			}
-			defer res.Body.Close()
-
-			if res.IsError() {
-				continue
-			}
-
-			var r types.SearchResponseESNOrder
-			if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-				log.Fatalf("Error parsing the response body: %s", err)
-				continue
-			}
-
-			for _, hit := range r.Hits.Hits {
-				log.Printf(" * ID=%s", hit.ID)
-				NewOrder = hit.Source
-				NOID = hit.ID
-				break
-			}
-
-			v := reflect.ValueOf(NewOrder)
-			// check get NEW_ORDER
-			if !v.IsZero() {
-				break
-			}
-		}
 
-		// new order lock
-		req := esapi.DeleteRequest{
-			Index:      "NEW_ORDER",
-			DocumentID: string(NOID),
-		}
+	var doc types.GetResponseES
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	db.debugf(" * ID=%s", doc.ID)
 
-		// delete
-		_, err := req.Do(ctx, db.Client)
-		if err != nil {
+	if r != nil {
+		if err := json.Unmarshal(doc.Source, r); err != nil {
 			return nil, err
 		}
-
-		return &NewOrder, nil
 	}
 
-	return nil, nil
+	return &doc, nil
 }
 
-func (db *ElasticSearch) DeleteNewOrder(ctx context.Context, orderId int, warehouseId int, districtId int) error {
-	if db.lock {
-		return nil
+// mget fetches the documents ids of index in one round trip, in the order of ids.
+// Missing documents are skipped.
+func (db *ElasticSearch) mget(ctx context.Context, index string, ids []string, source []string) ([]types.GetResponseES, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(map[string]interface{}{"ids": ids}); err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-func (db *ElasticSearch) GetCustomer(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Customer, error) {
-	var customer models.Customer
-	m := types.Must{}
-	m[0] = map[string]map[string]interface{}{
-		"match": {"C_ID": customerId},
+	req := esapi.MgetRequest{
+		Index:  indexName(index),
+		Body:   &buf,
+		Source: source,
 	}
 
-	m[1] = map[string]map[string]interface{}{
-		"match": {"C_D_ID": districtId},
+	res, err := req.Do(ctx, db.Client)
+	if err != nil {
+		return nil, err
 	}
+	defer res.Body.Close()
 
-	m[2] = map[string]map[string]interface{}{
-		"match": {"C_W_ID": warehouseId},
+	if res.IsError() {
+		return nil, newError(res, index, "")
 	}
-	var q types.BoolMustQuery
 
-	q.Query.Bool.Must = m
+	var r types.MgetResponseES
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, err
+	}
 
-	//!TODO: check version
+	var docs []types.GetResponseES
+	for _, doc := range r.Docs {
+		db.debugf(" * ID=%s found=%t", doc.ID, doc.Found)
+		if doc.Found {
+			docs = append(docs, doc)
+		}
+	}
 
-	indexes := [1]string{"CUSOMTER"}
+	return docs, nil
+}
 
-	qString, err := json.Marshal(q)
-	if err != nil {
-		return nil, err
+// search runs query against index and decodes the response into r.
+// source limits the returned fields, nil returns the whole document.
+func (db *ElasticSearch) search(ctx context.Context, index string, query interface{}, source []string, r interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return err
 	}
 
 	req := esapi.SearchRequest{
-		Index: indexes[:],
-		Query: string(qString),
+		Index:  []string{indexName(index)},
+		Body:   &buf,
+		Source: source,
 	}
 
 	res, err := req.Do(ctx, db.Client)
-
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return err
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return nil, err
+		return newError(res, index, "")
 	}
 
-	var r types.SearchResponseESCustomer
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		log.Fatalf("Error parsing the response body: %s", err)
-		return nil, err
+	return json.NewDecoder(res.Body).Decode(r)
+}
+
+func boolMust(must types.Must) types.BoolMustQuery {
+	var q types.BoolMustQuery
+	q.Query.Bool.Must = must
+	return q
+}
+
+// CheckNewOrder returns the oldest new order of the district without deleting it
+func (db *ElasticSearch) CheckNewOrder(ctx context.Context, warehouseId int, districtId int) (*models.NewOrder, *string, error) {
+	hit, err := db.oldestNewOrder(ctx, warehouseId, districtId)
+	if err != nil || hit == nil {
+		return nil, nil, err
 	}
 
-	for _, hit := range r.Hits.Hits {
-		log.Printf(" * ID=%s", hit.ID)
-		customer = hit.Source
-		break
+	var NewOrder models.NewOrder
+	if err := json.Unmarshal(hit.Source, &NewOrder); err != nil {
+		return nil, nil, err
 	}
 
-	return &customer, nil
+	return &NewOrder, &hit.ID, nil
 }
 
-// GetCId
-func (db *ElasticSearch) GetCustomerIdOrder(ctx context.Context, orderId int, warehouseId int, districtId int) (int, error) {
-
-	m := types.Must{}
-	m[0] = map[string]map[string]interface{}{
-		"match": {"O_ID": orderId},
+// It also deletes new order, as ElasticSearch can do that lock is set to 0.
+// With lock the delete only succeeds if nobody delivered the order in between.
+func (db *ElasticSearch) GetNewOrder(ctx context.Context, warehouseId int, districtId int) (*models.NewOrder, error) {
+	hit, err := db.oldestNewOrder(ctx, warehouseId, districtId)
+	if err != nil {
+		return nil, err
 	}
 
-	m[1] = map[string]map[string]interface{}{
-		"match": {"O_D_ID": districtId},
+	if hit == nil {
+		return nil, fmt.Errorf("no new order found for warehouse %d district %d", warehouseId, districtId)
 	}
 
-	m[2] = map[string]map[string]interface{}{
-		"match": {"O_W_ID": warehouseId},
+	var NewOrder models.NewOrder
+	if err := json.Unmarshal(hit.Source, &NewOrder); err != nil {
+		return nil, err
 	}
 
-	var q types.BoolMustQuery
-
-	q.Query.Bool.Must = m
-
-	//!TODO: check version
-
-	indexes := [1]string{"ORDERS"}
-
-	qString, err := json.Marshal(q)
-	if err != nil {
-		return 0, err
+	req := esapi.DeleteRequest{
+		Index:      indexName("NEW_ORDER"),
+		DocumentID: hit.ID,
+		Refresh:    db.refresh,
 	}
-	var CID int
 
-	req := esapi.SearchRequest{
-		Index: indexes[:],
-		Query: string(qString),
+	if db.lock {
+		seqNo := int(hit.SeqNo)
+		primaryTerm := int(hit.PrimaryTerm)
+		req.IfSeqNo = &seqNo
+		req.IfPrimaryTerm = &primaryTerm
 	}
 
 	res, err := req.Do(ctx, db.Client)
-
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return 0, err
+		return nil, newError(res, "NEW_ORDER", hit.ID)
 	}
 
-	var r types.SearchResponseESOrder
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		log.Fatalf("Error parsing the response body: %s", err)
-		return 0, err
+	return &NewOrder, nil
+}
+
+// oldestNewOrder returns the new order of the district with the lowest NO_O_ID, nil if there is none
+func (db *ElasticSearch) oldestNewOrder(ctx context.Context, warehouseId int, districtId int) (*types.HitResponseESVersioned, error) {
+	q := map[string]interface{}{
+		"query": boolMust(types.Must{
+			{"match": {"NO_D_ID": districtId}},
+			{"match": {"NO_W_ID": warehouseId}},
+		}).Query,
+		"sort":                []map[string]string{{"NO_O_ID": "asc"}},
+		"size":                1,
+		"seq_no_primary_term": true,
 	}
 
-	for _, hit := range r.Hits.Hits {
-		log.Printf(" * ID=%s", hit.ID)
-		CID = hit.Source.O_C_ID
-		break
+	var r types.SearchResponseESVersioned
+	if err := db.search(ctx, "NEW_ORDER", q, nil, &r); err != nil {
+		return nil, err
 	}
 
-	return CID, nil
+	if len(r.Hits.Hits) == 0 {
+		return nil, nil
+	}
+	db.debugf(" * ID=%s", r.Hits.Hits[0].ID)
 
-	// var err error
+	return &r.Hits.Hits[0], nil
+}
 
-	// filter := bson.D{
-	// 	{"O_ID", orderId},
-	// 	{"O_D_ID", districtId},
-	// 	{"O_W_ID", warehouseId},
-	// }
+// GetNewOrder already deleted it
+func (db *ElasticSearch) DeleteNewOrder(ctx context.Context, orderId int, warehouseId int, districtId int) error {
+	return nil
+}
 
-	// var doc bson.M
-	// err = db.C.Collection("ORDERS").FindOne(
-	// 	db.ctx,
-	// 	filter,
-	// 	options.FindOne().SetProjection(bson.D{
-	// 		{"_id", 0},
-	// 		{"O_C_ID", 1},
-	// 	})).Decode(&doc)
+func (db *ElasticSearch) GetCustomer(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Customer, error) {
+	var customer models.Customer
 
-	// if err != nil {
-	// 	return 0, err
-	// }
+	_, err := db.get(ctx, "CUSTOMER", key(warehouseId, districtId, customerId), &customer)
+	if err != nil {
+		return nil, err
+	}
 
-	// return int(doc["O_C_ID"].(int32)), nil
+	return &customer, nil
 }
 
-func (db *ElasticSearch) UpdateOrders(ctx context.Context, orderId int, warehouseId int, districtId int, oCarrierId int, deliveryDate time.Time) error {
-	// var err error
-
-	// filter := bson.D{
-	// 	{"O_ID", orderId},
-	// 	{"O_D_ID", districtId},
-	// 	{"O_W_ID", warehouseId},
-	// }
+// GetCId
+func (db *ElasticSearch) GetCustomerIdOrder(ctx context.Context, orderId int, warehouseId int, districtId int) (int, error) {
+	var order models.Order
 
-	// r, err := db.C.Collection("ORDERS").UpdateOne(db.ctx,
-	// 	filter,
-	// 	bson.D{
-	// 		{"$set", bson.D{
-	// 			{"O_CARRIER_ID", oCarrierId},
-	// 			{"ORDER_LINE.$[].OL_DELIVERY_D", deliveryDate},
-	// 		}},
-	// 	})
+	_, err := db.get(ctx, "ORDERS", key(warehouseId, districtId, orderId), &order)
+	if err != nil {
+		return 0, err
+	}
 
-	// if err != nil {
-	// 	return err
-	// }
+	return order.O_C_ID, nil
+}
 
-	// if r.MatchedCount == 0 {
-	// 	return fmt.Errorf("UpdateOrders: no documents matched")
-	// }
+func (db *ElasticSearch) UpdateOrders(ctx context.Context, orderId int, warehouseId int, districtId int, oCarrierId int, deliveryDate time.Time) error {
+	// ORDER_LINE is only embedded for orders created by New-Order
+	script := map[string]interface{}{
+		"source": "ctx._source.O_CARRIER_ID = params.carrier; " +
+			"if (ctx._source.ORDER_LINE != null) { for (ol in ctx._source.ORDER_LINE) { ol.OL_DELIVERY_D = params.date } }",
+		"params": map[string]interface{}{
+			"carrier": oCarrierId,
+			"date":    deliveryDate,
+		},
+	}
 
-	return nil
+	return db.update(ctx, "ORDERS", key(warehouseId, districtId, orderId), script, false)
 }
 
 func (db *ElasticSearch) SumOLAmount(ctx context.Context, orderId int, warehouseId int, districtId int) (float64, error) {
@@ -602,31 +592,14 @@ func (db *ElasticSearch) SumOLAmount(ctx context.Context, orderId int, warehouse
 }
 
 func (db *ElasticSearch) UpdateCustomer(ctx context.Context, customerId int, warehouseId int, districtId int, sumOlTotal float64) error {
-	// var err error
-
-	// r, err := db.C.Collection("CUSTOMER").UpdateOne(db.ctx,
-	// 	bson.D{
-	// 		{"C_ID", customerId},
-	// 		{"C_D_ID", districtId},
-	// 		{"C_W_ID", warehouseId},
-	// 	},
-	// 	bson.D{
-	// 		{"$inc", bson.D{
-	// 			{"C_BALANCE", sumOlTotal},
-	// 		}},
-	// 	},
-	// 	nil,
-	// )
-
-	// if err != nil {
-	// 	return err
-	// }
-
-	// if r.MatchedCount == 0 {
-	// 	return fmt.Errorf("no matched documents")
-	// }
+	script := map[string]interface{}{
+		"source": "ctx._source.C_BALANCE += params.amount",
+		"params": map[string]interface{}{
+			"amount": sumOlTotal,
+		},
+	}
 
-	return nil
+	return db.update(ctx, "CUSTOMER", key(warehouseId, districtId, customerId), script, false)
 }
 
 func (db *ElasticSearch) GetNextOrderId(ctx context.Context, warehouseId int, districtId int) (int, error) {
@@ -704,62 +677,7 @@ func (db *ElasticSearch) GetStockCount(ctx context.Context, orderIdLt int, order
 }
 
 func (db *ElasticSearch) GetCustomerById(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Customer, error) {
-	var customer models.Customer
-	m := types.Must{}
-	m[0] = map[string]map[string]interface{}{
-		"match": {"C_ID": customerId},
-	}
-
-	m[1] = map[string]map[string]interface{}{
-		"match": {"C_D_ID": districtId},
-	}
-
-	m[2] = map[string]map[string]interface{}{
-		"match": {"C_W_ID": warehouseId},
-	}
-
-	var q types.BoolMustQuery
-
-	q.Query.Bool.Must = m
-
-	//!TODO: check version
-
-	indexes := [1]string{"CUSOMTER"}
-
-	qString, err := json.Marshal(q)
-	if err != nil {
-		return nil, err
-	}
-
-	req := esapi.SearchRequest{
-		Index: indexes[:],
-		Query: string(qString),
-	}
-
-	res, err := req.Do(ctx, db.Client)
-
-	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return nil, err
-	}
-
-	var r types.SearchResponseESCustomer
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		log.Fatalf("Error parsing the response body: %s", err)
-		return nil, err
-	}
-
-	for _, hit := range r.Hits.Hits {
-		log.Printf(" * ID=%s", hit.ID)
-		customer = hit.Source
-		break
-	}
-
-	return &customer, nil
+	return db.GetCustomer(ctx, customerId, warehouseId, districtId)
 }
 
 func (db *ElasticSearch) GetCustomerByName(ctx context.Context, name string, warehouseId int, districtId int) (*models.Customer, error) {
@@ -853,157 +771,51 @@ func (db *ElasticSearch) GetOrderLines(ctx context.Context, orderId int, warehou
 }
 
 func (db *ElasticSearch) GetWarehouse(ctx context.Context, warehouseId int) (*models.Warehouse, error) {
-
-	var err error
 	var warehouse models.Warehouse
 
-	var buf bytes.Buffer
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"match": map[string]interface{}{
-				"W_ID": warehouseId,
-			},
-		},
-	}
-
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		log.Fatalf("Error encoding query: %s", err)
-	}
-	indexes := [1]string{"WAREHOUSE"}
-
-	req := esapi.SearchRequest{
-		Index: indexes[:],
-		Query: buf.String(),
-	}
-
-	res, err := req.Do(ctx, db.Client)
-
+	_, err := db.get(ctx, "WAREHOUSE", key(warehouseId), &warehouse)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
 		return nil, err
 	}
 
-	var r types.SearchResponseESWarehouse
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		log.Fatalf("Error parsing the response body: %s", err)
-		return nil, err
-	}
-
-	for _, hit := range r.Hits.Hits {
-		log.Printf(" * ID=%s", hit.ID)
-		warehouse = hit.Source
-		break
-	}
-
 	return &warehouse, nil
 }
 
 func (db *ElasticSearch) UpdateWarehouseBalance(ctx context.Context, warehouseId int, amount float64) error {
+	script := map[string]interface{}{
+		"source": "ctx._source.W_YTD += params.amount",
+		"params": map[string]interface{}{
+			"amount": amount,
+		},
+	}
 
-	// r, err := db.C.Collection("WAREHOUSE").UpdateOne(db.ctx, bson.D{
-	// 	{"W_ID", warehouseId},
-	// },
-	// 	bson.D{
-	// 		{"$inc", bson.D{
-	// 			{"W_YTD", amount},
-	// 		}},
-	// 	},
-	// )
-
-	// if err != nil {
-	// 	return err
-	// }
-
-	// if r.MatchedCount == 0 {
-	// 	return fmt.Errorf("no warehouse found")
-	// }
-
-	return nil
+	return db.update(ctx, "WAREHOUSE", key(warehouseId), script, false)
 }
 
 func (db *ElasticSearch) GetDistrict(ctx context.Context, warehouseId int, districtId int) (*models.District, error) {
-
-	var err error
 	var district models.District
 
-	m := types.Must{}
-	m[0] = map[string]map[string]interface{}{
-		"match": {"D_ID": district},
-	}
-
-	m[1] = map[string]map[string]interface{}{
-		"match": {"D_W_ID": warehouseId},
-	}
-	var q types.BoolMustQuery
-	q.Query.Bool.Must = m
-
-	indexes := [1]string{"DISTRICT"}
-
-	qString, err := json.Marshal(q)
+	doc, err := db.get(ctx, "DISTRICT", key(warehouseId, districtId), &district)
 	if err != nil {
 		return nil, err
 	}
 
-	req := esapi.SearchRequest{
-		Index: indexes[:],
-		Query: string(qString),
-	}
-
-	res, err := req.Do(ctx, db.Client)
-
-	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return nil, err
-	}
-
-	var r types.SearchResponseESDistrict
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		log.Fatalf("Error parsing the response body: %s", err)
-		return nil, err
-	}
-
-	for _, hit := range r.Hits.Hits {
-		log.Printf(" * ID=%s", hit.ID)
-		district = hit.Source
-		break
-	}
+	// New-Order starts with the district, the versions of a previous one are not needed anymore
+	db.read = map[string]version{}
+	db.remember("DISTRICT", *doc)
 
 	return &district, nil
 }
 
 func (db *ElasticSearch) UpdateDistrictBalance(ctx context.Context, warehouseId int, districtId int, amount float64) error {
-	// filter := bson.D{
-	// 	{"D_ID", districtId},
-	// 	{"D_W_ID", warehouseId},
-	// }
-
-	// update := bson.D{
-	// 	{"$inc", bson.D{
-	// 		{"D_YTD", amount},
-	// 	}},
-	// }
-
-	// r, err := db.C.Collection("DISTRICT").UpdateOne(db.ctx, filter, update, nil)
-
-	// if r.MatchedCount == 0 {
-	// 	return fmt.Errorf("No district found")
-	// }
-
-	// if err != nil {
-	// 	return err
-	// }
+	script := map[string]interface{}{
+		"source": "ctx._source.D_YTD += params.amount",
+		"params": map[string]interface{}{
+			"amount": amount,
+		},
+	}
 
-	return nil
+	return db.update(ctx, "DISTRICT", key(warehouseId, districtId), script, false)
 }
 
 func (db *ElasticSearch) InsertHistory(ctx context.Context,
@@ -1030,34 +842,20 @@ func (db *ElasticSearch) InsertHistory(ctx context.Context,
 
 func (db *ElasticSearch) UpdateCredit(ctx context.Context, customerId int, warehouseId int, districtId int, balance float64, data string) error {
 	//updateBCCustomer
-	// var err error
-	// update := bson.D{
-	// 	{"$inc", bson.D{
-	// 		{"C_BALANCE", -1 * balance},
-	// 		{"C_YTD_PAYMENT", balance},
-	// 		{"C_PAYMENT_CNT", 1},
-	// 	}},
-	// }
-
-	// if len(data) > 0 {
-	// 	update = append(update, bson.E{"$set", bson.D{
-	// 		{"C_DATA", data},
-	// 	}})
-	// }
-
-	// _, err = db.C.Collection("CUSTOMER").UpdateOne(db.ctx,
-	// 	bson.D{
-	// 		{"C_ID", customerId},
-	// 		{"C_W_ID", warehouseId},
-	// 		{"C_D_ID", districtId},
-	// 	},
-	// 	update, nil)
+	source := "ctx._source.C_BALANCE -= params.balance; ctx._source.C_YTD_PAYMENT += params.balance; ctx._source.C_PAYMENT_CNT += 1"
+	if len(data) > 0 {
+		source += "; ctx._source.C_DATA = params.data"
+	}
 
-	// if err != nil {
-	// 	return err
-	// }
+	script := map[string]interface{}{
+		"source": source,
+		"params": map[string]interface{}{
+			"balance": balance,
+			"data":    data,
+		},
+	}
 
-	return nil
+	return db.update(ctx, "CUSTOMER", key(warehouseId, districtId, customerId), script, false)
 }
 
 func (db *ElasticSearch) CreateOrder(ctx context.Context,
@@ -1099,7 +897,7 @@ func (db *ElasticSearch) CreateOrder(ctx context.Context,
 	err = db.InsertOne(ctx, "ORDERS", order)
 
 	if err != nil {
-		return nil
+		return err
 	}
 
 	return nil
@@ -1107,220 +905,79 @@ func (db *ElasticSearch) CreateOrder(ctx context.Context,
 
 //todo: sharding
 func (db *ElasticSearch) GetItems(ctx context.Context, itemIds []int) (*[]models.Item, error) {
-
-	var items []models.Item
-	var t types.Terms
-	t[0] = map[string]map[string][]int{"terms": {"I_ID": itemIds}}
-
-	var q types.TermsQuery
-	q.Query.Bool.Terms = t
-
-	indexes := [1]string{"ITEM"}
-
-	qString, err := json.Marshal(q)
-	if err != nil {
-		return nil, err
-	}
-
-	req := esapi.SearchRequest{
-		Index: indexes[:],
-		Query: string(qString),
+	ids := make([]string, len(itemIds))
+	for i, id := range itemIds {
+		ids[i] = key(id)
 	}
 
-	res, err := req.Do(ctx, db.Client)
-
+	docs, err := db.mget(ctx, "ITEM", ids, nil)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
 		return nil, err
 	}
 
-	var r types.SearchResponseESItem
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		log.Fatalf("Error parsing the response body: %s", err)
-		return nil, err
+	var items []models.Item
+	for _, doc := range docs {
+		var item models.Item
+		if err := json.Unmarshal(doc.Source, &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
 	}
 
-	for _, hit := range r.Hits.Hits {
-		log.Printf(" * ID=%s", hit.ID)
-		items = append(items, hit.Source)
-	}
 	return &items, nil
 }
 
 func (db *ElasticSearch) GetStockInfo(ctx context.Context, districtId int, iIds []int, iWids []int, allLocal int) (*[]models.Stock, error) {
 	distCol := fmt.Sprintf("S_DIST_%02d", districtId)
-	indexes := [1]string{"STOCK"}
-
-	stockProjection := [8]string{"S_I_ID", "S_W_ID", "S_QUANTITY", "S_DATA", "S_YTD", "S_ORDER_CNT", "S_REMOTE_CNT", distCol}
-
-	// stockProjection := bson.D{
-	// 	{"_id", 0},
-	// 	{"S_I_ID", 1},
-	// 	{"S_W_ID", 1},
-	// 	{"S_QUANTITY", 1},
-	// 	{"S_DATA", 1},
-	// 	{"S_YTD", 1},
-	// 	{"S_ORDER_CNT", 1},
-	// 	{"S_REMOTE_CNT", 1},
-	// 	{distCol, 1},
-	// }
-
-	var stocks []models.Stock
-	// var cursor *mongo.Cursor
-	if allLocal == 1 {
-		var t types.Terms
-		t[0] = map[string]map[string][]int{"terms": {"S_I_ID": iIds}}
-		t[1] = map[string]map[string]int{"terms": {"S_W_ID": iWids[0]}}
 
-		var q types.TermsQuery
-		q.Query.Bool.Terms = t
+	stockProjection := []string{"S_I_ID", "S_W_ID", "S_QUANTITY", "S_DATA", "S_YTD", "S_ORDER_CNT", "S_REMOTE_CNT", distCol}
 
-		qString, err := json.Marshal(q)
-		if err != nil {
-			return nil, err
-		}
-
-		req := esapi.SearchRequest{
-			Source: stockProjection[:],
-			Index:  indexes[:],
-			Query:  string(qString),
-		}
-
-		res, err := req.Do(ctx, db.Client)
-
-		if err != nil {
-			log.Fatalf("Error getting response: %s", err)
+	ids := make([]string, len(iIds))
+	for i, iId := range iIds {
+		wId := iWids[0]
+		if allLocal != 1 {
+			wId = iWids[i]
 		}
-		defer res.Body.Close()
-
-		if res.IsError() {
-			return nil, err
-		}
-
-		var r types.SearchResponseESStock
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-			log.Fatalf("Error parsing the response body: %s", err)
-			return nil, err
-		}
-
-		for _, hit := range r.Hits.Hits {
-			log.Printf(" * ID=%s", hit.ID)
-			stocks = append(stocks, hit.Source)
-		}
-	} else {
-		var searchList []types.Terms
-		for item, value := range iIds {
-			var t types.Terms
-			t[0] = map[string]map[string]int{"terms": {"S_I_ID": value}}
-			t[1] = map[string]map[string]int{"terms": {"S_W_ID": iWids[item]}}
-			searchList = append(searchList, t)
-		}
-
-		var o types.ORQuery
-		qString, err := json.Marshal(o)
-		if err != nil {
-			return nil, err
-		}
-
-		o.Query.Bool.Filter.Bool.Terms = searchList
-
-		req := esapi.SearchRequest{
-			Source: stockProjection[:],
-			Index:  indexes[:],
-			Query:  string(qString),
-		}
-
-		res, err := req.Do(ctx, db.Client)
-
-		if err != nil {
-			log.Fatalf("Error getting response: %s", err)
-		}
-		defer res.Body.Close()
+		ids[i] = key(wId, iId)
+	}
 
-		if res.IsError() {
-			return nil, err
-		}
+	docs, err := db.mget(ctx, "STOCK", ids, stockProjection)
+	if err != nil {
+		return nil, err
+	}
+	db.remember("STOCK", docs...)
 
-		var r types.SearchResponseESStock
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-			log.Fatalf("Error parsing the response body: %s", err)
+	var stocks []models.Stock
+	for _, doc := range docs {
+		var stock models.Stock
+		if err := json.Unmarshal(doc.Source, &stock); err != nil {
 			return nil, err
 		}
-
-		for _, hit := range r.Hits.Hits {
-			log.Printf(" * ID=%s", hit.ID)
-			stocks = append(stocks, hit.Source)
-		}
+		stocks = append(stocks, stock)
 	}
 
 	return &stocks, nil
 }
 
 func (db *ElasticSearch) UpdateStock(ctx context.Context, stockId int, warehouseId int, quantity int, ytd int, ordercnt int, remotecnt int) error {
-	var doc bytes.Buffer
-	s := fmt.Sprintf("ctx._source.S_QUANTITY=%d;ctx._source.S_YTD=%d;ctx._source.S_ORDER_CNT=%d;ctx._source.S_REMOTE_CNT=%d;", quantity, ytd, ordercnt, remotecnt)
-	documentUp := map[string]interface{}{
-		"scripts": s,
-	}
-
-	if err := json.NewEncoder(&doc).Encode(documentUp); err != nil {
-		log.Fatalf("Error encoding query: %s", err)
-	}
-
-	m := types.Must{}
-	m[0] = map[string]map[string]interface{}{
-		"match": {"S_I_ID": stockId},
-	}
-
-	m[1] = map[string]map[string]interface{}{
-		"match": {"S_W_ID": warehouseId},
-	}
-
-	var q types.BoolMustQuery
-
-	q.Query.Bool.Must = m
-
-	//!TODO: check version
-
-	indexes := [1]string{"STOCK"}
-
-	refresh := new(bool)
-	*refresh = true
-
-	qString, err := json.Marshal(q)
-	if err != nil {
-		return err
-	}
-
-	req := esapi.UpdateByQueryRequest{
-		Index:   indexes[:],
-		Body:    &doc,
-		Refresh: refresh,
-		Pretty:  true,
-		Query:   string(qString),
-	}
-
-	res, err := req.Do(ctx, db.Client)
-	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+	script := map[string]interface{}{
+		"source": "ctx._source.S_QUANTITY = params.quantity; ctx._source.S_YTD = params.ytd; " +
+			"ctx._source.S_ORDER_CNT = params.ordercnt; ctx._source.S_REMOTE_CNT = params.remotecnt",
+		"params": map[string]interface{}{
+			"quantity":  quantity,
+			"ytd":       ytd,
+			"ordercnt":  ordercnt,
+			"remotecnt": remotecnt,
+		},
 	}
 
-	defer res.Body.Close()
+	return db.update(ctx, "STOCK", key(warehouseId, stockId), script, true)
+}
 
-	if res.IsError() {
-		log.Printf("[%s] Error delete document", res.Status())
-	} else {
-		var r map[string]interface{}
-		if errJs := json.NewDecoder(res.Body).Decode(&r); errJs != nil {
-			log.Printf("Error parsing the response body: %s", errJs)
-		} else {
-			log.Printf("[%s] %s; version=%d", res.Status(), r["result"], int(r["_version"].(float64)))
-		}
-	}
+func (db *ElasticSearch) IncrementDistrictOrderIdAndGet(ctx context.Context, warehouseId int, districtId int) (*models.District, error) {
+	return nil, fmt.Errorf("findAndModify is not supported by ElasticSearch")
+}
 
-	return nil
+func (db *ElasticSearch) UpdateStockAndGet(ctx context.Context, districtId int, stockId int, warehouseId int, quantity int, remote bool) (*models.Stock, error) {
+	return nil, fmt.Errorf("findAndModify is not supported by ElasticSearch")
 }
@@ -0,0 +1,83 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+// Error is a request Elasticsearch answered with an error status.
+// Conflicts and rejections are reported as temporary so the executor retries them.
+type Error struct {
+	StatusCode int
+	Type       string
+	Reason     string
+	Index      string
+	ID         string
+}
+
+func (e *Error) Error() string {
+	target := e.Index
+	if e.ID != "" {
+		target = fmt.Sprintf("%s/%s", e.Index, e.ID)
+	}
+	if e.Type == "" {
+		return fmt.Sprintf("elasticsearch: %s: status %d", target, e.StatusCode)
+	}
+	return fmt.Sprintf("elasticsearch: %s: status %d: %s: %s", target, e.StatusCode, e.Type, e.Reason)
+}
+
+// Conflict reports whether the document was modified by someone else between read and write
+func (e *Error) Conflict() bool {
+	return e.StatusCode == http.StatusConflict || e.Type == "version_conflict_engine_exception"
+}
+
+// Rejected reports whether the cluster refused the request because it is overloaded
+func (e *Error) Rejected() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.Type == "es_rejected_execution_exception"
+}
+
+func (e *Error) Temporary() bool {
+	return e.Conflict() || e.Rejected()
+}
+
+// newError builds an *Error from a failed response, reading the ES error type and reason from its body
+func newError(res *esapi.Response, index string, id string) error {
+	e := &Error{
+		StatusCode: res.StatusCode,
+		Index:      index,
+		ID:         id,
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return e
+	}
+
+	var r struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil || len(r.Error) == 0 {
+		return e
+	}
+
+	// error is either an object or, for some APIs, a plain string
+	var detail struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(r.Error, &detail); err == nil {
+		e.Type = detail.Type
+		e.Reason = detail.Reason
+	} else {
+		var reason string
+		if err := json.Unmarshal(r.Error, &reason); err == nil {
+			e.Reason = reason
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,43 @@
+package elasticsearch
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Percona-Lab/go-tpcc/tpcc/models"
+)
+
+// documentID returns the deterministic _id of a row: its primary key joined with '-',
+// warehouse first, e.g. "3-7" for district 7 of warehouse 3.
+// HISTORY has no primary key, its documents get an id generated by Elasticsearch.
+func documentID(d interface{}) string {
+	switch v := d.(type) {
+	case models.Warehouse:
+		return key(v.W_ID)
+	case models.District:
+		return key(v.D_W_ID, v.D_ID)
+	case models.Customer:
+		return key(v.C_W_ID, v.C_D_ID, v.C_ID)
+	case models.NewOrder:
+		return key(v.NO_W_ID, v.NO_D_ID, v.NO_O_ID)
+	case models.Order:
+		return key(v.O_W_ID, v.O_D_ID, v.O_ID)
+	case models.OrderLine:
+		return key(v.OL_W_ID, v.OL_D_ID, v.OL_O_ID, v.OL_NUMBER)
+	case models.Item:
+		return key(v.I_ID)
+	case models.Stock:
+		return key(v.S_W_ID, v.S_I_ID)
+	}
+
+	return ""
+}
+
+func key(ids ...int) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(id)
+	}
+
+	return strings.Join(s, "-")
+}
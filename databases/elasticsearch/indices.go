@@ -0,0 +1,148 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+// IndexSettings are applied to every TPC-C index.
+// Shards can only be set when the index is created, the rest is updated in CreateIndexes.
+type IndexSettings struct {
+	Shards             int
+	Replicas           int
+	RefreshInterval    string
+	TranslogDurability string
+}
+
+// ORDER_LINE is also loaded into its own index as the loader only embeds it for MongoDB
+var indices = []string{
+	"WAREHOUSE",
+	"DISTRICT",
+	"CUSTOMER",
+	"HISTORY",
+	"NEW_ORDER",
+	"ORDERS",
+	"ORDER_LINE",
+	"ITEM",
+	"STOCK",
+}
+
+// indexName maps a TPC-C table to its index, Elasticsearch only accepts lowercase index names
+func indexName(table string) string {
+	return strings.ToLower(table)
+}
+
+// CreateSchema creates all indices up front, so the loader does not fall back to auto-created
+// indices with default settings. Indices of a previous prepare are deleted first.
+func (db *ElasticSearch) CreateSchema() error {
+	ctx := context.Background()
+
+	var names []string
+	for _, table := range indices {
+		names = append(names, indexName(table))
+	}
+
+	ignoreUnavailable := true
+	del := esapi.IndicesDeleteRequest{
+		Index:             names,
+		IgnoreUnavailable: &ignoreUnavailable,
+	}
+
+	res, err := del.Do(ctx, db.Client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newError(res, strings.Join(names, ","), "")
+	}
+	db.debugf("[%s] deleted indices %s", res.Status(), strings.Join(names, ","))
+
+	for _, table := range indices {
+		body := map[string]interface{}{
+			"settings": map[string]interface{}{
+				"number_of_shards": db.settings.Shards,
+			},
+		}
+
+		if table == "ORDERS" {
+			body["mappings"] = map[string]interface{}{
+				"properties": map[string]interface{}{
+					"ORDER_LINE": map[string]interface{}{
+						"type": "nested",
+					},
+				},
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+
+		req := esapi.IndicesCreateRequest{
+			Index: indexName(table),
+			Body:  &buf,
+		}
+
+		res, err := req.Do(ctx, db.Client)
+		if err != nil {
+			return err
+		}
+
+		if res.IsError() {
+			defer res.Body.Close()
+			return newError(res, indexName(table), "")
+		}
+		res.Body.Close()
+		db.debugf("[%s] created index %s", res.Status(), indexName(table))
+	}
+
+	return nil
+}
+
+// CreateIndexes applies the dynamic index settings once the data is loaded
+func (db *ElasticSearch) CreateIndexes() error {
+	ctx := context.Background()
+
+	body := map[string]interface{}{
+		"index": map[string]interface{}{
+			"number_of_replicas":  db.settings.Replicas,
+			"refresh_interval":    db.settings.RefreshInterval,
+			"translog.durability": db.settings.TranslogDurability,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return err
+	}
+
+	var names []string
+	for _, table := range indices {
+		names = append(names, indexName(table))
+	}
+
+	req := esapi.IndicesPutSettingsRequest{
+		Index: names,
+		Body:  &buf,
+	}
+
+	res, err := req.Do(ctx, db.Client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newError(res, strings.Join(names, ","), "")
+	}
+	db.debugf("[%s] updated settings of %s", res.Status(), strings.Join(names, ","))
+
+	return nil
+}
@@ -1,6 +1,10 @@
 package types
 
-import "github.com/Percona-Lab/go-tpcc/tpcc/models"
+import (
+	"encoding/json"
+
+	"github.com/Percona-Lab/go-tpcc/tpcc/models"
+)
 
 type ResponseES struct {
 	ID            string   `json:"_id"`
@@ -14,6 +18,20 @@ type ResponseES struct {
 	Result        string   `json:"result"`
 }
 
+// GetResponseES is a single document fetched by _id, Source is decoded by the caller
+type GetResponseES struct {
+	ID          string          `json:"_id"`
+	Index       string          `json:"_index"`
+	Found       bool            `json:"found"`
+	SeqNo       int64           `json:"_seq_no"`
+	PrimaryTerm int64           `json:"_primary_term"`
+	Source      json.RawMessage `json:"_source"`
+}
+
+type MgetResponseES struct {
+	Docs []GetResponseES `json:"docs"`
+}
+
 type ShardsES struct {
 	Failed     int64 `json:"failed"`
 	Skipped    int64 `json:"skipped,omitempty"`
@@ -174,6 +192,21 @@ type HitResponseESStock struct {
 	Source models.Stock `json:"_source"`
 }
 
+// any index, with seq_no_primary_term
+type SearchResponseESVersioned struct {
+	Hits struct {
+		Hits []HitResponseESVersioned `json:"hits"`
+	} `json:"hits"`
+}
+
+type HitResponseESVersioned struct {
+	ID          string          `json:"_id"`
+	Index       string          `json:"_index"`
+	SeqNo       int64           `json:"_seq_no"`
+	PrimaryTerm int64           `json:"_primary_term"`
+	Source      json.RawMessage `json:"_source"`
+}
+
 // general
 type HitsResponseTotalES struct {
 	Relation string `json:"relation"`
@@ -201,7 +234,7 @@ type ORQuery struct {
 		Bool struct {
 			Filter struct {
 				Bool struct {
-					Terms []Terms `json:"should"`
+					Terms Terms `json:"should"`
 				} `json:"bool"`
 			} `json:"filter"`
 		} `json:"bool"`
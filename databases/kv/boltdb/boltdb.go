@@ -0,0 +1,143 @@
+package boltdb
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/databases"
+	"github.com/Percona-Lab/go-tpcc/databases/kv"
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucket = []byte("tpcc")
+
+// Bolt is a kv.Engine on a bbolt file, all keys live in one bucket.
+// bbolt allows a single writable transaction at a time, so transactions are serializable.
+type Bolt struct {
+	db *bolt.DB
+}
+
+var (
+	openMu sync.Mutex
+	// bbolt locks the file, connections to the same path have to share the handle
+	opened = map[string]*Bolt{}
+)
+
+func init() {
+	databases.Register("bbolt", func(o databases.Options) (databases.Database, error) {
+		return NewBolt(o.URI)
+	}, databases.Capabilities{
+		Description:   "embedded bbolt key-value store, --uri is the database file",
+		Transactions:  true,
+		FindAndModify: true,
+	})
+}
+
+// NewBolt opens the bbolt file at path and returns the TPC-C database stored in it
+func NewBolt(path string) (*kv.KV, error) {
+	b, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return kv.NewKV(b)
+}
+
+func open(path string) (*Bolt, error) {
+	openMu.Lock()
+	defer openMu.Unlock()
+
+	if b, ok := opened[path]; ok {
+		return b, nil
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	b := &Bolt{db: db}
+	opened[path] = b
+
+	return b, nil
+}
+
+func (b *Bolt) Begin(writable bool) (kv.Tx, error) {
+	tx, err := b.db.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+
+	return &boltTx{tx: tx, b: tx.Bucket(bucket)}, nil
+}
+
+func (b *Bolt) Close() error {
+	openMu.Lock()
+	defer openMu.Unlock()
+
+	for path, o := range opened {
+		if o == b {
+			delete(opened, path)
+		}
+	}
+
+	return b.db.Close()
+}
+
+type boltTx struct {
+	tx *bolt.Tx
+	b  *bolt.Bucket
+}
+
+func (t *boltTx) Get(key []byte) ([]byte, error) {
+	return t.b.Get(key), nil
+}
+
+func (t *boltTx) Put(key []byte, value []byte) error {
+	return t.b.Put(key, value)
+}
+
+func (t *boltTx) Delete(key []byte) error {
+	return t.b.Delete(key)
+}
+
+func (t *boltTx) Scan(start []byte, end []byte, fn func(key []byte, value []byte) bool) error {
+	c := t.b.Cursor()
+
+	var k, v []byte
+	if start == nil {
+		k, v = c.First()
+	} else {
+		k, v = c.Seek(start)
+	}
+
+	for ; k != nil; k, v = c.Next() {
+		if end != nil && bytes.Compare(k, end) >= 0 {
+			break
+		}
+		if !fn(k, v) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (t *boltTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *boltTx) Rollback() error {
+	return t.tx.Rollback()
+}
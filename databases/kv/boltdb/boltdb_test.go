@@ -0,0 +1,206 @@
+package boltdb
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/databases"
+	_ "github.com/Percona-Lab/go-tpcc/databases/memory"
+	"github.com/Percona-Lab/go-tpcc/executor"
+	"github.com/Percona-Lab/go-tpcc/helpers"
+	"github.com/Percona-Lab/go-tpcc/tpcc"
+	"github.com/Percona-Lab/go-tpcc/tpcc/models"
+)
+
+const (
+	testWarehouses = 2
+	testScale      = 10
+)
+
+// outcome is what a transaction returned, the error messages differ between drivers
+type outcome struct {
+	Failed   bool
+	Rollback bool
+}
+
+// snapshot is the state of a database as seen through databases.Database, the times are zeroed
+type snapshot struct {
+	Warehouses []models.Warehouse
+	Districts  []models.District
+	Customers  []models.Customer
+	LastOrders []models.Order
+	OrderLines [][]models.OrderLine
+	NewOrders  []*models.NewOrder
+	Stock      [][]models.Stock
+}
+
+// run loads conf, runs the same transactions for every driver and returns their outcomes and the state afterwards
+func run(t *testing.T, conf *tpcc.Configuration) ([]outcome, *snapshot) {
+	t.Helper()
+	ctx := context.Background()
+
+	helpers.Seed(1)
+	w, err := tpcc.NewWorker(conf, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.CreateSchema(); err != nil {
+		t.Fatal(err)
+	}
+	w.LoadItems(ctx)
+	for wId := 1; wId <= conf.WareHouses; wId++ {
+		if err := w.LoadWarehouse(ctx, wId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.CreateIndexes(); err != nil {
+		t.Fatal(err)
+	}
+
+	helpers.Seed(2)
+	transactions := []func(ctx context.Context) error{
+		w.DoNewOrder,
+		w.DoPayment,
+		w.DoOrderStatus,
+		w.DoDelivery,
+		w.DoStockLevelTrx,
+	}
+
+	var outcomes []outcome
+	for i := 0; i < 200; i++ {
+		for _, fn := range transactions {
+			err := fn(ctx)
+			outcomes = append(outcomes, outcome{Failed: err != nil, Rollback: errors.Is(err, executor.ErrRollback)})
+		}
+	}
+
+	db, err := databases.NewDatabase(conf.DBDriver, databases.Options{URI: conf.URI, DBName: conf.DBName})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return outcomes, read(t, db)
+}
+
+// read takes the snapshot of db
+func read(t *testing.T, db databases.Database) *snapshot {
+	t.Helper()
+	ctx := context.Background()
+	s := &snapshot{}
+
+	customers := int(tpcc.CUSTOMERS_PER_DISTRICT / testScale)
+	items := int(tpcc.NUM_ITEMS / testScale)
+
+	for wId := 1; wId <= testWarehouses; wId++ {
+		warehouse, err := db.GetWarehouse(ctx, wId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Warehouses = append(s.Warehouses, *warehouse)
+
+		for dId := 1; dId <= tpcc.DISTRICTS_PER_WAREHOUSE; dId++ {
+			district, err := db.GetDistrict(ctx, wId, dId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Districts = append(s.Districts, *district)
+
+			newOrder, _, err := db.CheckNewOrder(ctx, wId, dId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.NewOrders = append(s.NewOrders, newOrder)
+
+			for cId := 1; cId <= customers; cId++ {
+				customer, err := db.GetCustomerById(ctx, cId, wId, dId)
+				if err != nil {
+					t.Fatal(err)
+				}
+				customer.C_SINCE = time.Time{}
+				s.Customers = append(s.Customers, *customer)
+
+				order, err := db.GetLastOrder(ctx, cId, wId, dId)
+				if err != nil {
+					t.Fatal(err)
+				}
+				order.O_ENTRY_D = time.Time{}
+				s.LastOrders = append(s.LastOrders, *order)
+
+				lines, err := db.GetOrderLines(ctx, order.O_ID, wId, dId)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := range *lines {
+					(*lines)[i].OL_DELIVERY_D = time.Time{}
+				}
+				s.OrderLines = append(s.OrderLines, *lines)
+			}
+		}
+
+		var iIds, iWids []int
+		for iId := 1; iId <= items; iId++ {
+			iIds = append(iIds, iId)
+			iWids = append(iWids, wId)
+		}
+		stock, err := db.GetStockInfo(ctx, 1, iIds, iWids, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Stock = append(s.Stock, *stock)
+	}
+
+	return s
+}
+
+// TestOracle runs the same transactions against the memory driver and the bbolt adapter, they have to agree
+func TestOracle(t *testing.T) {
+	for _, findAndModify := range []bool{false, true} {
+		name := "update"
+		if findAndModify {
+			name = "findandmodify"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			conf := tpcc.Configuration{
+				DBName:        "oracle-" + name,
+				WareHouses:    testWarehouses,
+				ScaleFactor:   testScale,
+				Threads:       1,
+				Transactions:  true,
+				TrxMode:       "manual",
+				FindAndModify: findAndModify,
+				PercentFail:   10,
+			}
+
+			memory := conf
+			memory.DBDriver = "memory"
+			expectedOutcomes, expected := run(t, &memory)
+
+			bbolt := conf
+			bbolt.DBDriver = "bbolt"
+			bbolt.URI = filepath.Join(t.TempDir(), "tpcc.db")
+			outcomes, got := run(t, &bbolt)
+
+			if !reflect.DeepEqual(outcomes, expectedOutcomes) {
+				for i := range outcomes {
+					if outcomes[i] != expectedOutcomes[i] {
+						t.Fatalf("transaction %d: %+v, memory %+v", i, outcomes[i], expectedOutcomes[i])
+					}
+				}
+			}
+
+			v := reflect.ValueOf(got).Elem()
+			ev := reflect.ValueOf(expected).Elem()
+			for i := 0; i < v.NumField(); i++ {
+				if !reflect.DeepEqual(v.Field(i).Interface(), ev.Field(i).Interface()) {
+					t.Errorf("%s differ from the memory driver", v.Type().Field(i).Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package kv
+
+// Engine is an ordered key-value store with transactions, the KV adapter builds the TPC-C tables on top of it
+type Engine interface {
+	// Begin starts a transaction, only a writable one may call Put and Delete
+	Begin(writable bool) (Tx, error)
+	Close() error
+}
+
+// Tx is a transaction of an Engine.
+// Keys and values handed out by Get and Scan are only valid until the transaction ends.
+type Tx interface {
+	// Get returns nil if the key does not exist
+	Get(key []byte) ([]byte, error)
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	// Scan calls fn for every key in [start, end) in ascending order until fn returns false.
+	// A nil end scans to the last key. The engine must not be modified while scanning.
+	Scan(start []byte, end []byte, fn func(key []byte, value []byte) bool) error
+	Commit() error
+	Rollback() error
+}
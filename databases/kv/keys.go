@@ -0,0 +1,59 @@
+package kv
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// every table and secondary index lives under its own one byte prefix
+const (
+	warehouseTable byte = iota + 1
+	districtTable
+	customerTable
+	// C_W_ID, C_D_ID, C_LAST, C_FIRST, C_ID -> C_ID
+	customerNameIndex
+	historyTable
+	orderTable
+	// O_W_ID, O_D_ID, O_C_ID, O_ID -> O_ID
+	orderCustomerIndex
+	newOrderTable
+	orderLineTable
+	itemTable
+	stockTable
+)
+
+// key builds the key of a row from its table and primary key columns.
+// Ints are big endian with the sign bit flipped and strings are terminated by a zero byte,
+// so keys sort in the order of their columns and a prefix of the columns selects a range.
+func key(table byte, columns ...interface{}) []byte {
+	k := []byte{table}
+
+	for _, c := range columns {
+		switch v := c.(type) {
+		case int:
+			var b [8]byte
+			binary.BigEndian.PutUint64(b[:], uint64(v)^(1<<63))
+			k = append(k, b[:]...)
+		case string:
+			k = append(append(k, v...), 0)
+		default:
+			panic(fmt.Sprintf("unsupported key column %T", c))
+		}
+	}
+
+	return k
+}
+
+// prefixEnd returns the first key after all keys starting with prefix, nil if there is none
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package kv
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestKeyOrder(t *testing.T) {
+	// every key has to sort before the next one
+	tests := []struct {
+		name string
+		keys [][]interface{}
+	}{
+		{
+			name: "ints",
+			keys: [][]interface{}{{math.MinInt64}, {-256}, {-1}, {0}, {1}, {255}, {256}, {math.MaxInt64}},
+		},
+		{
+			name: "strings",
+			keys: [][]interface{}{{""}, {"A"}, {"AB"}, {"ABLE"}, {"B"}, {"BA"}},
+		},
+		{
+			name: "string prefix before longer string",
+			keys: [][]interface{}{{"SMITH", "ZOE"}, {"SMITHE", "ADAM"}},
+		},
+		{
+			name: "columns in order",
+			keys: [][]interface{}{{1, 2, "B"}, {1, 10, "A"}, {2, -5, "A"}, {2, 1, ""}, {2, 1, "A"}},
+		},
+		{
+			name: "negative second column",
+			keys: [][]interface{}{{1, -3}, {1, -2}, {1, 0}, {2, math.MinInt64}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 1; i < len(tt.keys); i++ {
+				a := key(customerTable, tt.keys[i-1]...)
+				b := key(customerTable, tt.keys[i]...)
+				if bytes.Compare(a, b) >= 0 {
+					t.Errorf("%v does not sort before %v", tt.keys[i-1], tt.keys[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKeyTables(t *testing.T) {
+	// the table prefix sorts before all columns
+	if bytes.Compare(key(districtTable, math.MaxInt64), key(customerTable, math.MinInt64)) >= 0 {
+		t.Error("tables overlap")
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix []byte
+		end    []byte
+	}{
+		{[]byte{1, 2, 3}, []byte{1, 2, 4}},
+		{[]byte{1, 2, 0xff}, []byte{1, 3}},
+		{[]byte{1, 0xff, 0xff}, []byte{2}},
+		{[]byte{0xff, 0xff}, nil},
+		{[]byte{}, nil},
+	}
+
+	for _, tt := range tests {
+		if got := prefixEnd(tt.prefix); !bytes.Equal(got, tt.end) || (got == nil) != (tt.end == nil) {
+			t.Errorf("prefixEnd(%v) = %v, want %v", tt.prefix, got, tt.end)
+		}
+	}
+}
+
+func TestPrefixRange(t *testing.T) {
+	// the keys with more columns than a prefix fall into [prefix, prefixEnd(prefix)), the others do not
+	tests := []struct {
+		name    string
+		prefix  []interface{}
+		inside  [][]interface{}
+		outside [][]interface{}
+	}{
+		{
+			name:    "district",
+			prefix:  []interface{}{1, 2},
+			inside:  [][]interface{}{{1, 2, math.MinInt64}, {1, 2, 0}, {1, 2, math.MaxInt64}, {1, 2, "SMITH", 7}},
+			outside: [][]interface{}{{1, 1, math.MaxInt64}, {1, 3, math.MinInt64}, {2, 2, 0}, {0, 2, 0}},
+		},
+		{
+			name:    "negative",
+			prefix:  []interface{}{-1},
+			inside:  [][]interface{}{{-1, math.MinInt64}, {-1, math.MaxInt64}},
+			outside: [][]interface{}{{-2, math.MaxInt64}, {0, math.MinInt64}},
+		},
+		{
+			name:    "last name",
+			prefix:  []interface{}{1, 1, "SMITH"},
+			inside:  [][]interface{}{{1, 1, "SMITH", ""}, {1, 1, "SMITH", "ZOE", 3}},
+			outside: [][]interface{}{{1, 1, "SMITHE", ""}, {1, 1, "SMIT", "ZOE"}, {1, 1, "SMITG", "A"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := key(customerNameIndex, tt.prefix...)
+			end := prefixEnd(start)
+
+			in := func(k []byte) bool {
+				return bytes.Compare(k, start) >= 0 && (end == nil || bytes.Compare(k, end) < 0)
+			}
+
+			for _, columns := range tt.inside {
+				if !in(key(customerNameIndex, columns...)) {
+					t.Errorf("%v is not in the range of %v", columns, tt.prefix)
+				}
+			}
+			for _, columns := range tt.outside {
+				if in(key(customerNameIndex, columns...)) {
+					t.Errorf("%v is in the range of %v", columns, tt.prefix)
+				}
+			}
+		})
+	}
+}
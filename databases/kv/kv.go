@@ -0,0 +1,816 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/tpcc/models"
+)
+
+// KV stores the TPC-C tables in an ordered key-value Engine.
+// Rows are JSON encoded under the key of their primary key, secondary indexes are maintained on insert.
+// Without a transaction every call runs in its own engine transaction.
+type KV struct {
+	engine Engine
+	tx     Tx
+}
+
+// historySeq tells apart history rows written in the same nanosecond
+var historySeq uint64
+
+func NewKV(engine Engine) (*KV, error) {
+	return &KV{
+		engine: engine,
+	}, nil
+}
+
+// view runs fn in the open transaction or in a new read-only one
+func (db *KV) view(fn func(tx Tx) error) error {
+	if db.tx != nil {
+		return fn(db.tx)
+	}
+
+	tx, err := db.engine.Begin(false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return fn(tx)
+}
+
+// update runs fn in the open transaction or in a new writable one that is committed if fn succeeds
+func (db *KV) update(fn func(tx Tx) error) error {
+	if db.tx != nil {
+		return fn(db.tx)
+	}
+
+	tx, err := db.engine.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// get decodes the row at k into v and reports whether it exists
+func get(tx Tx, k []byte, v interface{}) (bool, error) {
+	b, err := tx.Get(k)
+	if err != nil || b == nil {
+		return false, err
+	}
+
+	return true, json.Unmarshal(b, v)
+}
+
+func put(tx Tx, k []byte, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return tx.Put(k, b)
+}
+
+// putNew writes a row whose key must not exist yet
+func putNew(tx Tx, k []byte, v interface{}, what string) error {
+	b, err := tx.Get(k)
+	if err != nil {
+		return err
+	}
+	if b != nil {
+		return fmt.Errorf("duplicate %s", what)
+	}
+
+	return put(tx, k, v)
+}
+
+// modify decodes the row at k into v, applies fn and writes v back. It reports whether the row exists.
+func modify(tx Tx, k []byte, v interface{}, fn func()) (bool, error) {
+	ok, err := get(tx, k, v)
+	if !ok || err != nil {
+		return ok, err
+	}
+
+	fn()
+
+	return true, put(tx, k, v)
+}
+
+// scanPrefix calls fn with the value of every key starting with prefix until fn returns false
+func scanPrefix(tx Tx, prefix []byte, fn func(value []byte) (bool, error)) error {
+	var err error
+	scanErr := tx.Scan(prefix, prefixEnd(prefix), func(k []byte, v []byte) bool {
+		var next bool
+		next, err = fn(v)
+		return next && err == nil
+	})
+	if scanErr != nil {
+		return scanErr
+	}
+
+	return err
+}
+
+func (db *KV) StartTrx() error {
+	if db.tx != nil {
+		return fmt.Errorf("transaction already started")
+	}
+
+	tx, err := db.engine.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	db.tx = tx
+	return nil
+}
+
+func (db *KV) CommitTrx(ctx context.Context) error {
+	if db.tx == nil {
+		return fmt.Errorf("no transaction started")
+	}
+
+	tx := db.tx
+	db.tx = nil
+	return tx.Commit()
+}
+
+func (db *KV) RollbackTrx(ctx context.Context) error {
+	if db.tx == nil {
+		return fmt.Errorf("no transaction started")
+	}
+
+	tx := db.tx
+	db.tx = nil
+	return tx.Rollback()
+}
+
+// WithTransaction runs fn between StartTrx and CommitTrx, rolling back if fn fails
+func (db *KV) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := db.StartTrx()
+	if err != nil {
+		return err
+	}
+
+	err = fn(ctx)
+	if err != nil {
+		rErr := db.RollbackTrx(ctx)
+		if rErr != nil {
+			return rErr
+		}
+		return err
+	}
+
+	return db.CommitTrx(ctx)
+}
+
+// CreateSchema deletes every key, the tables need no schema
+func (db *KV) CreateSchema() error {
+	return db.update(func(tx Tx) error {
+		var keys [][]byte
+		err := tx.Scan(nil, nil, func(k []byte, v []byte) bool {
+			keys = append(keys, append([]byte(nil), k...))
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			err = tx.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// secondary indexes are written with the rows
+func (db *KV) CreateIndexes() error {
+	return nil
+}
+
+func (db *KV) InsertOne(ctx context.Context, tableName string, d interface{}) error {
+	return db.update(func(tx Tx) error {
+		return insert(tx, tableName, d)
+	})
+}
+
+func (db *KV) InsertBatch(ctx context.Context, tableName string, d []interface{}) error {
+	return db.update(func(tx Tx) error {
+		for _, item := range d {
+			err := insert(tx, tableName, item)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// insert writes a row and its index entries to the table matching its type
+func insert(tx Tx, tableName string, d interface{}) error {
+	switch v := d.(type) {
+	case models.Warehouse:
+		return putNew(tx, key(warehouseTable, v.W_ID), v, "warehouse")
+
+	case models.District:
+		return putNew(tx, key(districtTable, v.D_W_ID, v.D_ID), v, "district")
+
+	case models.Customer:
+		err := putNew(tx, key(customerTable, v.C_W_ID, v.C_D_ID, v.C_ID), v, "customer")
+		if err != nil {
+			return err
+		}
+		return put(tx, key(customerNameIndex, v.C_W_ID, v.C_D_ID, v.C_LAST, v.C_FIRST, v.C_ID), v.C_ID)
+
+	case models.History:
+		seq := atomic.AddUint64(&historySeq, 1)
+		return put(tx, key(historyTable, v.H_W_ID, v.H_D_ID, int(time.Now().UnixNano()), int(seq)), v)
+
+	case models.Order:
+		return insertOrder(tx, v)
+
+	case models.NewOrder:
+		return putNew(tx, key(newOrderTable, v.NO_W_ID, v.NO_D_ID, v.NO_O_ID), v, "new order")
+
+	case models.OrderLine:
+		return putNew(tx, key(orderLineTable, v.OL_W_ID, v.OL_D_ID, v.OL_O_ID, v.OL_NUMBER), v, "order line")
+
+	case models.Item:
+		return putNew(tx, key(itemTable, v.I_ID), v, "item")
+
+	case models.Stock:
+		return putNew(tx, key(stockTable, v.S_W_ID, v.S_I_ID), v, "stock")
+
+	default:
+		return fmt.Errorf("unknown row type %T for %s", d, tableName)
+	}
+}
+
+// insertOrder keeps embedded order lines in the ORDER_LINE table
+func insertOrder(tx Tx, o models.Order) error {
+	lines := o.ORDER_LINE
+	o.ORDER_LINE = nil
+
+	err := putNew(tx, key(orderTable, o.O_W_ID, o.O_D_ID, o.O_ID), o, "order")
+	if err != nil {
+		return err
+	}
+
+	err = put(tx, key(orderCustomerIndex, o.O_W_ID, o.O_D_ID, o.O_C_ID, o.O_ID), o.O_ID)
+	if err != nil {
+		return err
+	}
+
+	for _, ol := range lines {
+		err = insert(tx, "ORDER_LINE", ol)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// orderLines returns the lines of an order ordered by OL_NUMBER
+func orderLines(tx Tx, orderId int, warehouseId int, districtId int) ([]models.OrderLine, error) {
+	var lines []models.OrderLine
+
+	err := scanPrefix(tx, key(orderLineTable, warehouseId, districtId, orderId), func(value []byte) (bool, error) {
+		var ol models.OrderLine
+		err := json.Unmarshal(value, &ol)
+		lines = append(lines, ol)
+		return true, err
+	})
+
+	return lines, err
+}
+
+func (db *KV) IncrementDistrictOrderId(ctx context.Context, warehouseId int, districtId int) error {
+	_, err := db.IncrementDistrictOrderIdAndGet(ctx, warehouseId, districtId)
+	return err
+}
+
+func (db *KV) IncrementDistrictOrderIdAndGet(ctx context.Context, warehouseId int, districtId int) (*models.District, error) {
+	var d models.District
+
+	err := db.update(func(tx Tx) error {
+		ok, err := modify(tx, key(districtTable, warehouseId, districtId), &d, func() {
+			d.D_NEXT_O_ID++
+		})
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match district")
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// only ElasticSearch needs it
+func (db *KV) CheckNewOrder(ctx context.Context, warehouseId int, districtId int) (*models.NewOrder, *string, error) {
+	return nil, nil, nil
+}
+
+// GetNewOrder returns the oldest undelivered order of the district
+func (db *KV) GetNewOrder(ctx context.Context, warehouseId int, districtId int) (*models.NewOrder, error) {
+	var no *models.NewOrder
+
+	err := db.view(func(tx Tx) error {
+		return scanPrefix(tx, key(newOrderTable, warehouseId, districtId), func(value []byte) (bool, error) {
+			no = &models.NewOrder{}
+			return false, json.Unmarshal(value, no)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if no == nil {
+		return nil, fmt.Errorf("no new order found for warehouse %d district %d", warehouseId, districtId)
+	}
+
+	return no, nil
+}
+
+func (db *KV) DeleteNewOrder(ctx context.Context, orderId int, warehouseId int, districtId int) error {
+	return db.update(func(tx Tx) error {
+		k := key(newOrderTable, warehouseId, districtId, orderId)
+
+		b, err := tx.Get(k)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			return fmt.Errorf("unable to match new order for delete")
+		}
+
+		return tx.Delete(k)
+	})
+}
+
+func (db *KV) GetCustomer(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Customer, error) {
+	var c models.Customer
+
+	err := db.view(func(tx Tx) error {
+		ok, err := get(tx, key(customerTable, warehouseId, districtId, customerId), &c)
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match customer")
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (db *KV) GetCustomerIdOrder(ctx context.Context, orderId int, warehouseId int, districtId int) (int, error) {
+	var o models.Order
+
+	err := db.view(func(tx Tx) error {
+		ok, err := get(tx, key(orderTable, warehouseId, districtId, orderId), &o)
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match order")
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return o.O_C_ID, nil
+}
+
+func (db *KV) UpdateOrders(ctx context.Context, orderId int, warehouseId int, districtId int, oCarrierId int, deliveryDate time.Time) error {
+	return db.update(func(tx Tx) error {
+		var o models.Order
+		ok, err := modify(tx, key(orderTable, warehouseId, districtId, orderId), &o, func() {
+			o.O_CARRIER_ID = oCarrierId
+		})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("unable to match order")
+		}
+
+		lines, err := orderLines(tx, orderId, warehouseId, districtId)
+		if err != nil {
+			return err
+		}
+
+		for _, ol := range lines {
+			ol.OL_DELIVERY_D = deliveryDate
+			err = put(tx, key(orderLineTable, warehouseId, districtId, orderId, ol.OL_NUMBER), ol)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (db *KV) SumOLAmount(ctx context.Context, orderId int, warehouseId int, districtId int) (float64, error) {
+	var sum float64
+
+	err := db.view(func(tx Tx) error {
+		lines, err := orderLines(tx, orderId, warehouseId, districtId)
+		for _, ol := range lines {
+			sum += ol.OL_AMOUNT
+		}
+		return err
+	})
+
+	return sum, err
+}
+
+func (db *KV) UpdateCustomer(ctx context.Context, customerId int, warehouseId int, districtId int, sumOlTotal float64) error {
+	return db.update(func(tx Tx) error {
+		var c models.Customer
+		ok, err := modify(tx, key(customerTable, warehouseId, districtId, customerId), &c, func() {
+			c.C_BALANCE += sumOlTotal
+		})
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match customer")
+		}
+		return err
+	})
+}
+
+func (db *KV) GetNextOrderId(ctx context.Context, warehouseId int, districtId int) (int, error) {
+	d, err := db.GetDistrict(ctx, warehouseId, districtId)
+	if err != nil {
+		return 0, err
+	}
+
+	return d.D_NEXT_O_ID, nil
+}
+
+// GetStockCount counts the distinct items of the orders in [orderIdGt, orderIdLt) with stock below threshold
+func (db *KV) GetStockCount(ctx context.Context, orderIdLt int, orderIdGt int, threshold int, warehouseId int, districtId int) (int64, error) {
+	var count int64
+
+	err := db.view(func(tx Tx) error {
+		seen := map[int]bool{}
+
+		err := tx.Scan(key(orderLineTable, warehouseId, districtId, orderIdGt), key(orderLineTable, warehouseId, districtId, orderIdLt), func(k []byte, v []byte) bool {
+			var ol models.OrderLine
+			if json.Unmarshal(v, &ol) == nil {
+				seen[ol.OL_I_ID] = true
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		for iId := range seen {
+			var s models.Stock
+			ok, err := get(tx, key(stockTable, warehouseId, iId), &s)
+			if err != nil {
+				return err
+			}
+			if ok && s.S_QUANTITY < threshold {
+				count++
+			}
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+func (db *KV) GetCustomerById(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Customer, error) {
+	return db.GetCustomer(ctx, customerId, warehouseId, districtId)
+}
+
+// GetCustomerByName returns the customer in the middle of those with the last name, ordered by first name
+func (db *KV) GetCustomerByName(ctx context.Context, name string, warehouseId int, districtId int) (*models.Customer, error) {
+	var c models.Customer
+
+	err := db.view(func(tx Tx) error {
+		var ids []int
+		err := scanPrefix(tx, key(customerNameIndex, warehouseId, districtId, name), func(value []byte) (bool, error) {
+			var id int
+			err := json.Unmarshal(value, &id)
+			ids = append(ids, id)
+			return true, err
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(ids) < 1 {
+			return fmt.Errorf("no customers found with given name: %s", name)
+		}
+
+		ok, err := get(tx, key(customerTable, warehouseId, districtId, ids[(len(ids)-1)/2]), &c)
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match customer")
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (db *KV) GetLastOrder(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Order, error) {
+	var o models.Order
+
+	err := db.view(func(tx Tx) error {
+		// the index is ordered by O_ID, the last entry is the newest order
+		oId := 0
+		err := scanPrefix(tx, key(orderCustomerIndex, warehouseId, districtId, customerId), func(value []byte) (bool, error) {
+			return true, json.Unmarshal(value, &oId)
+		})
+		if err != nil {
+			return err
+		}
+
+		if oId == 0 {
+			return fmt.Errorf("no orders found for customer %d", customerId)
+		}
+
+		ok, err := get(tx, key(orderTable, warehouseId, districtId, oId), &o)
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match order")
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &o, nil
+}
+
+func (db *KV) GetOrderLines(ctx context.Context, orderId int, warehouseId int, districtId int) (*[]models.OrderLine, error) {
+	var lines []models.OrderLine
+
+	err := db.view(func(tx Tx) error {
+		var err error
+		lines, err = orderLines(tx, orderId, warehouseId, districtId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &lines, nil
+}
+
+func (db *KV) GetWarehouse(ctx context.Context, warehouseId int) (*models.Warehouse, error) {
+	var w models.Warehouse
+
+	err := db.view(func(tx Tx) error {
+		ok, err := get(tx, key(warehouseTable, warehouseId), &w)
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match warehouse")
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+func (db *KV) UpdateWarehouseBalance(ctx context.Context, warehouseId int, amount float64) error {
+	return db.update(func(tx Tx) error {
+		var w models.Warehouse
+		ok, err := modify(tx, key(warehouseTable, warehouseId), &w, func() {
+			w.W_YTD += amount
+		})
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match warehouse")
+		}
+		return err
+	})
+}
+
+func (db *KV) GetDistrict(ctx context.Context, warehouseId int, districtId int) (*models.District, error) {
+	var d models.District
+
+	err := db.view(func(tx Tx) error {
+		ok, err := get(tx, key(districtTable, warehouseId, districtId), &d)
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match district")
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func (db *KV) UpdateDistrictBalance(ctx context.Context, warehouseId int, districtId int, amount float64) error {
+	return db.update(func(tx Tx) error {
+		var d models.District
+		ok, err := modify(tx, key(districtTable, warehouseId, districtId), &d, func() {
+			d.D_YTD += amount
+		})
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match district")
+		}
+		return err
+	})
+}
+
+func (db *KV) InsertHistory(ctx context.Context, warehouseId int, districtId int, date time.Time, amount float64, data string) error {
+	return db.InsertOne(ctx, "HISTORY", models.History{
+		H_D_ID:   districtId,
+		H_W_ID:   warehouseId,
+		H_C_W_ID: warehouseId,
+		H_C_D_ID: districtId,
+		H_DATE:   date,
+		H_AMOUNT: amount,
+		H_DATA:   data,
+	})
+}
+
+func (db *KV) UpdateCredit(ctx context.Context, customerId int, warehouseId int, districtId int, balance float64, data string) error {
+	return db.update(func(tx Tx) error {
+		var c models.Customer
+		ok, err := modify(tx, key(customerTable, warehouseId, districtId, customerId), &c, func() {
+			c.C_BALANCE -= balance
+			c.C_YTD_PAYMENT += balance
+			c.C_PAYMENT_CNT++
+			if len(data) > 0 {
+				c.C_DATA = data
+			}
+		})
+		if err == nil && !ok {
+			return fmt.Errorf("no customers matched")
+		}
+		return err
+	})
+}
+
+func (db *KV) CreateOrder(ctx context.Context,
+	orderId int,
+	customerId int,
+	warehouseId int,
+	districtId int,
+	oCarrierId int,
+	oOlCnt int,
+	allLocal int,
+	orderEntryDate time.Time,
+	orderLine []models.OrderLine,
+) error {
+	// like the SQL drivers, the order lines are not delivered yet
+	lines := make([]models.OrderLine, len(orderLine))
+	for i, ol := range orderLine {
+		ol.OL_D_ID = districtId
+		ol.OL_W_ID = warehouseId
+		ol.OL_DELIVERY_D = time.Time{}
+		lines[i] = ol
+	}
+
+	return db.update(func(tx Tx) error {
+		err := insertOrder(tx, models.Order{
+			O_ID:         orderId,
+			O_C_ID:       customerId,
+			O_D_ID:       districtId,
+			O_W_ID:       warehouseId,
+			O_ENTRY_D:    orderEntryDate,
+			O_CARRIER_ID: oCarrierId,
+			O_OL_CNT:     oOlCnt,
+			O_ALL_LOCAL:  allLocal,
+			ORDER_LINE:   lines,
+		})
+		if err != nil {
+			return err
+		}
+
+		return insert(tx, "NEW_ORDER", models.NewOrder{
+			NO_O_ID: orderId,
+			NO_D_ID: districtId,
+			NO_W_ID: warehouseId,
+		})
+	})
+}
+
+// GetItems returns the items in the order of itemIds, unknown ids are skipped
+func (db *KV) GetItems(ctx context.Context, itemIds []int) (*[]models.Item, error) {
+	var items []models.Item
+
+	err := db.view(func(tx Tx) error {
+		for _, id := range itemIds {
+			var item models.Item
+			ok, err := get(tx, key(itemTable, id), &item)
+			if err != nil {
+				return err
+			}
+			if ok {
+				items = append(items, item)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &items, nil
+}
+
+func (db *KV) UpdateStock(ctx context.Context, stockId int, warehouseId int, quantity int, ytd int, ordercnt int, remotecnt int) error {
+	return db.update(func(tx Tx) error {
+		var s models.Stock
+		ok, err := modify(tx, key(stockTable, warehouseId, stockId), &s, func() {
+			s.S_QUANTITY = quantity
+			s.S_YTD = ytd
+			s.S_ORDER_CNT = ordercnt
+			s.S_REMOTE_CNT = remotecnt
+		})
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match stock")
+		}
+		return err
+	})
+}
+
+// UpdateStockAndGet applies the New-Order stock update and returns the new row
+func (db *KV) UpdateStockAndGet(ctx context.Context, districtId int, stockId int, warehouseId int, quantity int, remote bool) (*models.Stock, error) {
+	var s models.Stock
+
+	err := db.update(func(tx Tx) error {
+		ok, err := modify(tx, key(stockTable, warehouseId, stockId), &s, func() {
+			if s.S_QUANTITY >= quantity+10 {
+				s.S_QUANTITY -= quantity
+			} else {
+				s.S_QUANTITY += 91 - quantity
+			}
+			s.S_YTD += quantity
+			s.S_ORDER_CNT++
+			if remote {
+				s.S_REMOTE_CNT++
+			}
+		})
+		if err == nil && !ok {
+			return fmt.Errorf("unable to match stock")
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetStockInfo returns one row per requested item, in the order of iIds
+func (db *KV) GetStockInfo(ctx context.Context, districtId int, iIds []int, iWids []int, allLocal int) (*[]models.Stock, error) {
+	var stocks []models.Stock
+
+	err := db.view(func(tx Tx) error {
+		for i, iId := range iIds {
+			wId := iWids[0]
+			if allLocal != 1 {
+				wId = iWids[i]
+			}
+
+			var s models.Stock
+			ok, err := get(tx, key(stockTable, wId, iId), &s)
+			if err != nil {
+				return err
+			}
+			if ok {
+				stocks = append(stocks, s)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &stocks, nil
+}
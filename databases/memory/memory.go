@@ -0,0 +1,729 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/databases"
+	"github.com/Percona-Lab/go-tpcc/tpcc/models"
+)
+
+// Memory keeps all tables in process memory. Connections opened with the same dbname share the data.
+// A transaction holds the store lock exclusively from StartTrx to CommitTrx/RollbackTrx,
+// so transactions are serializable, and rollback replays an undo log.
+type Memory struct {
+	s    *store
+	isTx bool
+	undo []func()
+}
+
+func init() {
+	databases.Register("memory", func(o databases.Options) (databases.Database, error) {
+		return NewMemory(o.DBName)
+	}, databases.Capabilities{
+		Description:   "in-process reference implementation, --db names the dataset",
+		Transactions:  true,
+		FindAndModify: true,
+		InProcess:     true,
+	})
+}
+
+func NewMemory(dbname string) (*Memory, error) {
+	return &Memory{
+		s: openStore(dbname),
+	}, nil
+}
+
+// lock locks the store for writing unless the transaction already holds it, the result unlocks it again
+func (db *Memory) lock() func() {
+	if db.isTx {
+		return func() {}
+	}
+
+	db.s.mu.Lock()
+	return db.s.mu.Unlock
+}
+
+func (db *Memory) rlock() func() {
+	if db.isTx {
+		return func() {}
+	}
+
+	db.s.mu.RLock()
+	return db.s.mu.RUnlock
+}
+
+// onRollback records how to revert a change made inside a transaction
+func (db *Memory) onRollback(fn func()) {
+	if db.isTx {
+		db.undo = append(db.undo, fn)
+	}
+}
+
+func (db *Memory) StartTrx() error {
+	if db.isTx {
+		return fmt.Errorf("transaction already started")
+	}
+
+	db.s.mu.Lock()
+	db.isTx = true
+	db.undo = nil
+	return nil
+}
+
+func (db *Memory) CommitTrx(ctx context.Context) error {
+	if !db.isTx {
+		return fmt.Errorf("no transaction started")
+	}
+
+	db.isTx = false
+	db.undo = nil
+	db.s.mu.Unlock()
+	return nil
+}
+
+func (db *Memory) RollbackTrx(ctx context.Context) error {
+	if !db.isTx {
+		return fmt.Errorf("no transaction started")
+	}
+
+	// replaying must not record new undo entries
+	undo := db.undo
+	db.isTx = false
+	db.undo = nil
+
+	for i := len(undo) - 1; i >= 0; i-- {
+		undo[i]()
+	}
+
+	db.s.mu.Unlock()
+	return nil
+}
+
+// WithTransaction runs fn between StartTrx and CommitTrx, rolling back if fn fails
+func (db *Memory) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := db.StartTrx()
+	if err != nil {
+		return err
+	}
+
+	err = fn(ctx)
+	if err != nil {
+		rErr := db.RollbackTrx(ctx)
+		if rErr != nil {
+			return rErr
+		}
+		return err
+	}
+
+	return db.CommitTrx(ctx)
+}
+
+// CreateSchema drops all data of the database
+func (db *Memory) CreateSchema() error {
+	db.s.reset()
+	return nil
+}
+
+// every lookup is by key already
+func (db *Memory) CreateIndexes() error {
+	return nil
+}
+
+func (db *Memory) InsertOne(ctx context.Context, tableName string, d interface{}) error {
+	defer db.lock()()
+
+	return db.insert(tableName, d)
+}
+
+func (db *Memory) InsertBatch(ctx context.Context, tableName string, d []interface{}) error {
+	defer db.lock()()
+
+	for _, item := range d {
+		err := db.insert(tableName, item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insert adds a row to the table matching its type, the store has to be locked
+func (db *Memory) insert(tableName string, d interface{}) error {
+	s := db.s
+
+	switch v := d.(type) {
+	case models.Warehouse:
+		if _, ok := s.warehouses[v.W_ID]; ok {
+			return fmt.Errorf("duplicate warehouse %d", v.W_ID)
+		}
+		s.warehouses[v.W_ID] = v
+		db.onRollback(func() { delete(s.warehouses, v.W_ID) })
+
+	case models.District:
+		k := districtKey{v.D_W_ID, v.D_ID}
+		if _, ok := s.districts[k]; ok {
+			return fmt.Errorf("duplicate district %v", k)
+		}
+		s.districts[k] = v
+		db.onRollback(func() { delete(s.districts, k) })
+
+	case models.Customer:
+		k := customerKey{v.C_W_ID, v.C_D_ID, v.C_ID}
+		if _, ok := s.customers[k]; ok {
+			return fmt.Errorf("duplicate customer %v", k)
+		}
+		s.customers[k] = v
+		nk := nameKey{v.C_W_ID, v.C_D_ID, v.C_LAST}
+		s.customersByName[nk] = append(s.customersByName[nk], v.C_ID)
+		db.onRollback(func() {
+			delete(s.customers, k)
+			s.customersByName[nk] = s.customersByName[nk][:len(s.customersByName[nk])-1]
+		})
+
+	case models.History:
+		s.history = append(s.history, v)
+		db.onRollback(func() { s.history = s.history[:len(s.history)-1] })
+
+	case models.Order:
+		return db.insertOrder(v)
+
+	case models.NewOrder:
+		return db.insertNewOrder(v)
+
+	case models.OrderLine:
+		k := orderKey{v.OL_W_ID, v.OL_D_ID, v.OL_O_ID}
+		s.orderLines[k] = append(s.orderLines[k], v)
+		db.onRollback(func() { s.orderLines[k] = s.orderLines[k][:len(s.orderLines[k])-1] })
+
+	case models.Item:
+		if _, ok := s.items[v.I_ID]; ok {
+			return fmt.Errorf("duplicate item %d", v.I_ID)
+		}
+		s.items[v.I_ID] = v
+		db.onRollback(func() { delete(s.items, v.I_ID) })
+
+	case models.Stock:
+		k := stockKey{v.S_W_ID, v.S_I_ID}
+		if _, ok := s.stock[k]; ok {
+			return fmt.Errorf("duplicate stock %v", k)
+		}
+		s.stock[k] = v
+		db.onRollback(func() { delete(s.stock, k) })
+
+	default:
+		return fmt.Errorf("unknown row type %T for %s", d, tableName)
+	}
+
+	return nil
+}
+
+// insertOrder keeps embedded order lines in the ORDER_LINE table
+func (db *Memory) insertOrder(o models.Order) error {
+	s := db.s
+
+	k := orderKey{o.O_W_ID, o.O_D_ID, o.O_ID}
+	if _, ok := s.orders[k]; ok {
+		return fmt.Errorf("duplicate order %v", k)
+	}
+
+	lines := o.ORDER_LINE
+	o.ORDER_LINE = nil
+	s.orders[k] = o
+	db.onRollback(func() { delete(s.orders, k) })
+
+	ck := customerKey{o.O_W_ID, o.O_D_ID, o.O_C_ID}
+	if last, ok := s.lastOrder[ck]; !ok || o.O_ID > last {
+		s.lastOrder[ck] = o.O_ID
+		db.onRollback(func() {
+			if ok {
+				s.lastOrder[ck] = last
+			} else {
+				delete(s.lastOrder, ck)
+			}
+		})
+	}
+
+	for _, ol := range lines {
+		err := db.insert("ORDER_LINE", ol)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *Memory) insertNewOrder(no models.NewOrder) error {
+	s := db.s
+
+	k := districtKey{no.NO_W_ID, no.NO_D_ID}
+	ids := s.newOrders[k]
+	i := sort.SearchInts(ids, no.NO_O_ID)
+	if i < len(ids) && ids[i] == no.NO_O_ID {
+		return fmt.Errorf("duplicate new order %d for %v", no.NO_O_ID, k)
+	}
+
+	s.newOrders[k] = append(ids[:i:i], append([]int{no.NO_O_ID}, ids[i:]...)...)
+	db.onRollback(func() { db.removeNewOrder(k, no.NO_O_ID) })
+
+	return nil
+}
+
+func (db *Memory) removeNewOrder(k districtKey, orderId int) bool {
+	ids := db.s.newOrders[k]
+	i := sort.SearchInts(ids, orderId)
+	if i == len(ids) || ids[i] != orderId {
+		return false
+	}
+
+	db.s.newOrders[k] = append(ids[:i:i], ids[i+1:]...)
+	return true
+}
+
+func (db *Memory) IncrementDistrictOrderId(ctx context.Context, warehouseId int, districtId int) error {
+	_, err := db.IncrementDistrictOrderIdAndGet(ctx, warehouseId, districtId)
+	return err
+}
+
+func (db *Memory) IncrementDistrictOrderIdAndGet(ctx context.Context, warehouseId int, districtId int) (*models.District, error) {
+	defer db.lock()()
+
+	k := districtKey{warehouseId, districtId}
+	d, ok := db.s.districts[k]
+	if !ok {
+		return nil, fmt.Errorf("unable to match district")
+	}
+
+	db.setDistrict(k, func(d *models.District) { d.D_NEXT_O_ID++ })
+	d = db.s.districts[k]
+
+	return &d, nil
+}
+
+// setDistrict applies fn to the stored district, the store has to be locked
+func (db *Memory) setDistrict(k districtKey, fn func(d *models.District)) {
+	old := db.s.districts[k]
+	d := old
+	fn(&d)
+	db.s.districts[k] = d
+	db.onRollback(func() { db.s.districts[k] = old })
+}
+
+func (db *Memory) setCustomer(k customerKey, fn func(c *models.Customer)) {
+	old := db.s.customers[k]
+	c := old
+	fn(&c)
+	db.s.customers[k] = c
+	db.onRollback(func() { db.s.customers[k] = old })
+}
+
+func (db *Memory) setStock(k stockKey, fn func(s *models.Stock)) {
+	old := db.s.stock[k]
+	st := old
+	fn(&st)
+	db.s.stock[k] = st
+	db.onRollback(func() { db.s.stock[k] = old })
+}
+
+// only ElasticSearch needs it
+func (db *Memory) CheckNewOrder(ctx context.Context, warehouseId int, districtId int) (*models.NewOrder, *string, error) {
+	return nil, nil, nil
+}
+
+func (db *Memory) GetNewOrder(ctx context.Context, warehouseId int, districtId int) (*models.NewOrder, error) {
+	defer db.rlock()()
+
+	ids := db.s.newOrders[districtKey{warehouseId, districtId}]
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no new order found for warehouse %d district %d", warehouseId, districtId)
+	}
+
+	return &models.NewOrder{
+		NO_O_ID: ids[0],
+		NO_D_ID: districtId,
+		NO_W_ID: warehouseId,
+	}, nil
+}
+
+func (db *Memory) DeleteNewOrder(ctx context.Context, orderId int, warehouseId int, districtId int) error {
+	defer db.lock()()
+
+	k := districtKey{warehouseId, districtId}
+	if !db.removeNewOrder(k, orderId) {
+		return fmt.Errorf("unable to match new order for delete")
+	}
+	db.onRollback(func() {
+		db.insertNewOrder(models.NewOrder{NO_O_ID: orderId, NO_D_ID: districtId, NO_W_ID: warehouseId})
+	})
+
+	return nil
+}
+
+func (db *Memory) GetCustomer(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Customer, error) {
+	defer db.rlock()()
+
+	c, ok := db.s.customers[customerKey{warehouseId, districtId, customerId}]
+	if !ok {
+		return nil, fmt.Errorf("unable to match customer")
+	}
+
+	return &c, nil
+}
+
+func (db *Memory) GetCustomerIdOrder(ctx context.Context, orderId int, warehouseId int, districtId int) (int, error) {
+	defer db.rlock()()
+
+	o, ok := db.s.orders[orderKey{warehouseId, districtId, orderId}]
+	if !ok {
+		return 0, fmt.Errorf("unable to match order")
+	}
+
+	return o.O_C_ID, nil
+}
+
+func (db *Memory) UpdateOrders(ctx context.Context, orderId int, warehouseId int, districtId int, oCarrierId int, deliveryDate time.Time) error {
+	defer db.lock()()
+
+	k := orderKey{warehouseId, districtId, orderId}
+	old, ok := db.s.orders[k]
+	if !ok {
+		return fmt.Errorf("unable to match order")
+	}
+
+	o := old
+	o.O_CARRIER_ID = oCarrierId
+	db.s.orders[k] = o
+
+	oldLines := db.s.orderLines[k]
+	lines := make([]models.OrderLine, len(oldLines))
+	for i, ol := range oldLines {
+		ol.OL_DELIVERY_D = deliveryDate
+		lines[i] = ol
+	}
+	db.s.orderLines[k] = lines
+
+	db.onRollback(func() {
+		db.s.orders[k] = old
+		db.s.orderLines[k] = oldLines
+	})
+
+	return nil
+}
+
+func (db *Memory) SumOLAmount(ctx context.Context, orderId int, warehouseId int, districtId int) (float64, error) {
+	defer db.rlock()()
+
+	var sum float64
+	for _, ol := range db.s.orderLines[orderKey{warehouseId, districtId, orderId}] {
+		sum += ol.OL_AMOUNT
+	}
+
+	return sum, nil
+}
+
+func (db *Memory) UpdateCustomer(ctx context.Context, customerId int, warehouseId int, districtId int, sumOlTotal float64) error {
+	defer db.lock()()
+
+	k := customerKey{warehouseId, districtId, customerId}
+	if _, ok := db.s.customers[k]; !ok {
+		return fmt.Errorf("unable to match customer")
+	}
+
+	db.setCustomer(k, func(c *models.Customer) { c.C_BALANCE += sumOlTotal })
+
+	return nil
+}
+
+func (db *Memory) GetNextOrderId(ctx context.Context, warehouseId int, districtId int) (int, error) {
+	defer db.rlock()()
+
+	d, ok := db.s.districts[districtKey{warehouseId, districtId}]
+	if !ok {
+		return 0, fmt.Errorf("unable to match district")
+	}
+
+	return d.D_NEXT_O_ID, nil
+}
+
+// GetStockCount counts the distinct items of the orders in [orderIdGt, orderIdLt) with stock below threshold
+func (db *Memory) GetStockCount(ctx context.Context, orderIdLt int, orderIdGt int, threshold int, warehouseId int, districtId int) (int64, error) {
+	defer db.rlock()()
+
+	seen := map[int]bool{}
+	var count int64
+
+	for o := orderIdGt; o < orderIdLt; o++ {
+		for _, ol := range db.s.orderLines[orderKey{warehouseId, districtId, o}] {
+			if seen[ol.OL_I_ID] {
+				continue
+			}
+			seen[ol.OL_I_ID] = true
+
+			s, ok := db.s.stock[stockKey{warehouseId, ol.OL_I_ID}]
+			if ok && s.S_QUANTITY < threshold {
+				count++
+			}
+		}
+	}
+
+	return count, nil
+}
+
+func (db *Memory) GetCustomerById(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Customer, error) {
+	return db.GetCustomer(ctx, customerId, warehouseId, districtId)
+}
+
+// GetCustomerByName returns the customer in the middle of those with the last name, ordered by first name
+func (db *Memory) GetCustomerByName(ctx context.Context, name string, warehouseId int, districtId int) (*models.Customer, error) {
+	defer db.rlock()()
+
+	var customers []models.Customer
+	for _, id := range db.s.customersByName[nameKey{warehouseId, districtId, name}] {
+		customers = append(customers, db.s.customers[customerKey{warehouseId, districtId, id}])
+	}
+
+	if len(customers) < 1 {
+		return nil, fmt.Errorf("no customers found with given name: %s", name)
+	}
+
+	sort.Slice(customers, func(i, j int) bool {
+		return customers[i].C_FIRST < customers[j].C_FIRST
+	})
+
+	return &customers[(len(customers)-1)/2], nil
+}
+
+func (db *Memory) GetLastOrder(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Order, error) {
+	defer db.rlock()()
+
+	oId, ok := db.s.lastOrder[customerKey{warehouseId, districtId, customerId}]
+	if !ok {
+		return nil, fmt.Errorf("no orders found for customer %d", customerId)
+	}
+
+	o := db.s.orders[orderKey{warehouseId, districtId, oId}]
+
+	return &o, nil
+}
+
+func (db *Memory) GetOrderLines(ctx context.Context, orderId int, warehouseId int, districtId int) (*[]models.OrderLine, error) {
+	defer db.rlock()()
+
+	lines := db.s.orderLines[orderKey{warehouseId, districtId, orderId}]
+	ol := make([]models.OrderLine, len(lines))
+	copy(ol, lines)
+
+	return &ol, nil
+}
+
+func (db *Memory) GetWarehouse(ctx context.Context, warehouseId int) (*models.Warehouse, error) {
+	defer db.rlock()()
+
+	w, ok := db.s.warehouses[warehouseId]
+	if !ok {
+		return nil, fmt.Errorf("unable to match warehouse")
+	}
+
+	return &w, nil
+}
+
+func (db *Memory) UpdateWarehouseBalance(ctx context.Context, warehouseId int, amount float64) error {
+	defer db.lock()()
+
+	old, ok := db.s.warehouses[warehouseId]
+	if !ok {
+		return fmt.Errorf("unable to match warehouse")
+	}
+
+	w := old
+	w.W_YTD += amount
+	db.s.warehouses[warehouseId] = w
+	db.onRollback(func() { db.s.warehouses[warehouseId] = old })
+
+	return nil
+}
+
+func (db *Memory) GetDistrict(ctx context.Context, warehouseId int, districtId int) (*models.District, error) {
+	defer db.rlock()()
+
+	d, ok := db.s.districts[districtKey{warehouseId, districtId}]
+	if !ok {
+		return nil, fmt.Errorf("unable to match district")
+	}
+
+	return &d, nil
+}
+
+func (db *Memory) UpdateDistrictBalance(ctx context.Context, warehouseId int, districtId int, amount float64) error {
+	defer db.lock()()
+
+	k := districtKey{warehouseId, districtId}
+	if _, ok := db.s.districts[k]; !ok {
+		return fmt.Errorf("unable to match district")
+	}
+
+	db.setDistrict(k, func(d *models.District) { d.D_YTD += amount })
+
+	return nil
+}
+
+func (db *Memory) InsertHistory(ctx context.Context, warehouseId int, districtId int, date time.Time, amount float64, data string) error {
+	defer db.lock()()
+
+	return db.insert("HISTORY", models.History{
+		H_D_ID:   districtId,
+		H_W_ID:   warehouseId,
+		H_C_W_ID: warehouseId,
+		H_C_D_ID: districtId,
+		H_DATE:   date,
+		H_AMOUNT: amount,
+		H_DATA:   data,
+	})
+}
+
+func (db *Memory) UpdateCredit(ctx context.Context, customerId int, warehouseId int, districtId int, balance float64, data string) error {
+	defer db.lock()()
+
+	k := customerKey{warehouseId, districtId, customerId}
+	if _, ok := db.s.customers[k]; !ok {
+		return fmt.Errorf("no customers matched")
+	}
+
+	db.setCustomer(k, func(c *models.Customer) {
+		c.C_BALANCE -= balance
+		c.C_YTD_PAYMENT += balance
+		c.C_PAYMENT_CNT++
+		if len(data) > 0 {
+			c.C_DATA = data
+		}
+	})
+
+	return nil
+}
+
+func (db *Memory) CreateOrder(ctx context.Context,
+	orderId int,
+	customerId int,
+	warehouseId int,
+	districtId int,
+	oCarrierId int,
+	oOlCnt int,
+	allLocal int,
+	orderEntryDate time.Time,
+	orderLine []models.OrderLine,
+) error {
+	defer db.lock()()
+
+	// like the SQL drivers, the order lines are not delivered yet
+	lines := make([]models.OrderLine, len(orderLine))
+	for i, ol := range orderLine {
+		ol.OL_D_ID = districtId
+		ol.OL_W_ID = warehouseId
+		ol.OL_DELIVERY_D = time.Time{}
+		lines[i] = ol
+	}
+
+	err := db.insertOrder(models.Order{
+		O_ID:         orderId,
+		O_C_ID:       customerId,
+		O_D_ID:       districtId,
+		O_W_ID:       warehouseId,
+		O_ENTRY_D:    orderEntryDate,
+		O_CARRIER_ID: oCarrierId,
+		O_OL_CNT:     oOlCnt,
+		O_ALL_LOCAL:  allLocal,
+		ORDER_LINE:   lines,
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.insertNewOrder(models.NewOrder{
+		NO_O_ID: orderId,
+		NO_D_ID: districtId,
+		NO_W_ID: warehouseId,
+	})
+}
+
+// GetItems returns the items in the order of itemIds, unknown ids are skipped
+func (db *Memory) GetItems(ctx context.Context, itemIds []int) (*[]models.Item, error) {
+	defer db.rlock()()
+
+	var items []models.Item
+	for _, id := range itemIds {
+		item, ok := db.s.items[id]
+		if ok {
+			items = append(items, item)
+		}
+	}
+
+	return &items, nil
+}
+
+func (db *Memory) UpdateStock(ctx context.Context, stockId int, warehouseId int, quantity int, ytd int, ordercnt int, remotecnt int) error {
+	defer db.lock()()
+
+	k := stockKey{warehouseId, stockId}
+	if _, ok := db.s.stock[k]; !ok {
+		return fmt.Errorf("unable to match stock")
+	}
+
+	db.setStock(k, func(s *models.Stock) {
+		s.S_QUANTITY = quantity
+		s.S_YTD = ytd
+		s.S_ORDER_CNT = ordercnt
+		s.S_REMOTE_CNT = remotecnt
+	})
+
+	return nil
+}
+
+// UpdateStockAndGet applies the New-Order stock update and returns the new row
+func (db *Memory) UpdateStockAndGet(ctx context.Context, districtId int, stockId int, warehouseId int, quantity int, remote bool) (*models.Stock, error) {
+	defer db.lock()()
+
+	k := stockKey{warehouseId, stockId}
+	if _, ok := db.s.stock[k]; !ok {
+		return nil, fmt.Errorf("unable to match stock")
+	}
+
+	db.setStock(k, func(s *models.Stock) {
+		if s.S_QUANTITY >= quantity+10 {
+			s.S_QUANTITY -= quantity
+		} else {
+			s.S_QUANTITY += 91 - quantity
+		}
+		s.S_YTD += quantity
+		s.S_ORDER_CNT++
+		if remote {
+			s.S_REMOTE_CNT++
+		}
+	})
+	s := db.s.stock[k]
+
+	return &s, nil
+}
+
+// GetStockInfo returns one row per requested item, in the order of iIds
+func (db *Memory) GetStockInfo(ctx context.Context, districtId int, iIds []int, iWids []int, allLocal int) (*[]models.Stock, error) {
+	defer db.rlock()()
+
+	var stocks []models.Stock
+	for i, iId := range iIds {
+		wId := iWids[0]
+		if allLocal != 1 {
+			wId = iWids[i]
+		}
+
+		s, ok := db.s.stock[stockKey{wId, iId}]
+		if ok {
+			stocks = append(stocks, s)
+		}
+	}
+
+	return &stocks, nil
+}
@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/executor"
+	"github.com/Percona-Lab/go-tpcc/helpers"
+	"github.com/Percona-Lab/go-tpcc/tpcc"
+)
+
+const (
+	testDB         = "test"
+	testWarehouses = 2
+)
+
+// the dataset is loaded only once as that takes most of the time, every test runs on it
+var (
+	loadOnce sync.Once
+	loadErr  error
+)
+
+// load runs the loader of prepare against the memory database testDB on first use and returns
+// the configuration to run transactions on it
+func load(t *testing.T, findAndModify bool) *tpcc.Configuration {
+	t.Helper()
+
+	conf := &tpcc.Configuration{
+		DBDriver:      "memory",
+		DBName:        testDB,
+		WareHouses:    testWarehouses,
+		ScaleFactor:   1,
+		Threads:       1,
+		Transactions:  true,
+		TrxMode:       "manual",
+		FindAndModify: findAndModify,
+		PercentFail:   10,
+	}
+
+	loadOnce.Do(func() {
+		helpers.Seed(1)
+
+		var w *tpcc.Worker
+		w, loadErr = tpcc.NewWorker(conf, nil, nil, 0)
+		if loadErr != nil {
+			return
+		}
+
+		loadErr = w.CreateSchema()
+		if loadErr != nil {
+			return
+		}
+
+		ctx := context.Background()
+		w.LoadItems(ctx)
+		for wId := 1; wId <= testWarehouses; wId++ {
+			loadErr = w.LoadWarehouse(ctx, wId)
+			if loadErr != nil {
+				return
+			}
+		}
+
+		loadErr = w.CreateIndexes()
+	})
+
+	if loadErr != nil {
+		t.Fatal(loadErr)
+	}
+
+	return conf
+}
+
+// checkConsistency checks the TPC-C consistency conditions 2 to 4 of every district and that
+// exactly the orders without a carrier are waiting in NEW_ORDER
+func checkConsistency(t *testing.T, s *store) {
+	t.Helper()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	maxOrder := map[districtKey]int{}
+	undelivered := map[districtKey]int{}
+	for k, o := range s.orders {
+		dk := districtKey{k.w, k.d}
+		if o.O_ID > maxOrder[dk] {
+			maxOrder[dk] = o.O_ID
+		}
+		if o.O_CARRIER_ID == 0 {
+			undelivered[dk]++
+		}
+
+		if lines := len(s.orderLines[k]); lines != o.O_OL_CNT {
+			t.Errorf("order %v: O_OL_CNT %d, %d order lines", k, o.O_OL_CNT, lines)
+		}
+	}
+
+	for k, d := range s.districts {
+		if d.D_NEXT_O_ID-1 != maxOrder[k] {
+			t.Errorf("district %v: D_NEXT_O_ID %d, max O_ID %d", k, d.D_NEXT_O_ID, maxOrder[k])
+		}
+
+		newOrders := s.newOrders[k]
+		if len(newOrders) != undelivered[k] {
+			t.Errorf("district %v: %d new orders, %d undelivered orders", k, len(newOrders), undelivered[k])
+		}
+
+		if n := len(newOrders); n > 0 {
+			if newOrders[n-1] != maxOrder[k] {
+				t.Errorf("district %v: max NO_O_ID %d, max O_ID %d", k, newOrders[n-1], maxOrder[k])
+			}
+			if newOrders[n-1]-newOrders[0]+1 != n {
+				t.Errorf("district %v: %d new orders from %d to %d", k, n, newOrders[0], newOrders[n-1])
+			}
+		}
+	}
+}
+
+// ytdGaps returns W_YTD minus the sum of D_YTD by warehouse, Payment has to keep them
+func ytdGaps(s *store) map[int]float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gaps := map[int]float64{}
+	for wId, w := range s.warehouses {
+		gaps[wId] = w.W_YTD
+	}
+	for k, d := range s.districts {
+		gaps[k.w] -= d.D_YTD
+	}
+
+	return gaps
+}
+
+func TestLoad(t *testing.T) {
+	load(t, false)
+
+	db, err := NewMemory(testDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.s.warehouses) != testWarehouses {
+		t.Errorf("%d warehouses loaded", len(db.s.warehouses))
+	}
+	checkConsistency(t, db.s)
+}
+
+func TestTransactions(t *testing.T) {
+	for _, findAndModify := range []bool{false, true} {
+		name := "update"
+		if findAndModify {
+			name = "findandmodify"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			conf := load(t, findAndModify)
+
+			db, err := NewMemory(testDB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gaps := ytdGaps(db.s)
+
+			w, err := tpcc.NewWorker(conf, nil, nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			transactions := []func(ctx context.Context) error{
+				w.DoNewOrder,
+				w.DoPayment,
+				w.DoOrderStatus,
+				w.DoDelivery,
+				w.DoStockLevelTrx,
+			}
+
+			for i := 0; i < 500; i++ {
+				for _, fn := range transactions {
+					err := fn(ctx)
+					if err != nil && !errors.Is(err, executor.ErrRollback) {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			checkConsistency(t, db.s)
+
+			for wId, gap := range ytdGaps(db.s) {
+				if math.Abs(gap-gaps[wId]) > 0.001 {
+					t.Errorf("warehouse %d: W_YTD - sum(D_YTD) changed from %.2f to %.2f", wId, gaps[wId], gap)
+				}
+			}
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	for _, findAndModify := range []bool{false, true} {
+		name := "update"
+		if findAndModify {
+			name = "findandmodify"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			conf := load(t, findAndModify)
+
+			db, err := NewMemory(testDB)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ex, err := executor.NewExecutor(db, 256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ex.ChangeTransactions(true)
+			ex.ChangeFindAndModify(findAndModify)
+
+			k := districtKey{1, 1}
+			district := db.s.districts[k]
+			orders := len(db.s.orders)
+			stock := db.s.stock[stockKey{1, 1}]
+
+			// the last item id is unused, as in the New-Order transactions rolled back on purpose
+			unused := int(float64(tpcc.NUM_ITEMS)/conf.ScaleFactor) + 1
+			err = ex.DoNewOrderTrx(context.Background(), 1, 1, 1, time.Now(), []int{1, unused}, []int{1, 1}, []int{5, 5})
+			if !errors.Is(err, executor.ErrRollback) {
+				t.Fatalf("expected ErrRollback, got %v", err)
+			}
+
+			if got := db.s.districts[k]; got != district {
+				t.Errorf("district changed by the rollback: %+v, was %+v", got, district)
+			}
+			if got := len(db.s.orders); got != orders {
+				t.Errorf("%d orders after the rollback, %d before", got, orders)
+			}
+			if got := db.s.stock[stockKey{1, 1}]; got != stock {
+				t.Errorf("stock changed by the rollback: %+v, was %+v", got, stock)
+			}
+
+			checkConsistency(t, db.s)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/Percona-Lab/go-tpcc/tpcc/models"
+)
+
+type districtKey struct {
+	w, d int
+}
+
+type customerKey struct {
+	w, d, c int
+}
+
+type nameKey struct {
+	w, d int
+	last string
+}
+
+type orderKey struct {
+	w, d, o int
+}
+
+type stockKey struct {
+	w, i int
+}
+
+// store holds the tables of one database, it is shared by every connection opened with the same name
+type store struct {
+	mu sync.RWMutex
+
+	warehouses      map[int]models.Warehouse
+	districts       map[districtKey]models.District
+	customers       map[customerKey]models.Customer
+	customersByName map[nameKey][]int
+	history         []models.History
+	orders          map[orderKey]models.Order
+	lastOrder       map[customerKey]int
+	// order ids waiting for delivery, ascending
+	newOrders  map[districtKey][]int
+	orderLines map[orderKey][]models.OrderLine
+	items      map[int]models.Item
+	stock      map[stockKey]models.Stock
+}
+
+var (
+	storesMu sync.Mutex
+	stores   = map[string]*store{}
+)
+
+func newStore() *store {
+	return &store{
+		warehouses:      map[int]models.Warehouse{},
+		districts:       map[districtKey]models.District{},
+		customers:       map[customerKey]models.Customer{},
+		customersByName: map[nameKey][]int{},
+		orders:          map[orderKey]models.Order{},
+		lastOrder:       map[customerKey]int{},
+		newOrders:       map[districtKey][]int{},
+		orderLines:      map[orderKey][]models.OrderLine{},
+		items:           map[int]models.Item{},
+		stock:           map[stockKey]models.Stock{},
+	}
+}
+
+// openStore returns the store of dbname, creating an empty one on first use
+func openStore(dbname string) *store {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	s, ok := stores[dbname]
+	if !ok {
+		s = newStore()
+		stores[dbname] = s
+	}
+
+	return s
+}
+
+// reset drops all tables
+func (s *store) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fresh := newStore()
+	s.warehouses = fresh.warehouses
+	s.districts = fresh.districts
+	s.customers = fresh.customers
+	s.customersByName = fresh.customersByName
+	s.history = nil
+	s.orders = fresh.orders
+	s.lastOrder = fresh.lastOrder
+	s.newOrders = fresh.newOrders
+	s.orderLines = fresh.orderLines
+	s.items = fresh.items
+	s.stock = fresh.stock
+}
@@ -2,9 +2,11 @@ package mongodb
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"time"
 
+	"github.com/Percona-Lab/go-tpcc/databases"
 	"github.com/Percona-Lab/go-tpcc/tpcc/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,11 +21,46 @@ type MongoDB struct {
 	Aggregate     bool
 	findAndModify bool
 	transactions  bool
+	shard         bool
+	shardZones    bool
+	warehouses    int
 	ctx           mongo.SessionContext
 }
 
-func NewMongoDb(uri string, dbname string, transactions bool, findandmodify bool) (*MongoDB, error) {
-	client, err := mongo.NewClient(options.Client().ApplyURI(uri))
+func init() {
+	databases.Register("mongodb", func(o databases.Options) (databases.Database, error) {
+		return NewMongoDb(o.URI, o.DBName, o.Username, o.Password, o.TLS, o.Transactions, o.FindAndModify, o.Aggregate, o.Shard, o.ShardZones, o.WareHouses)
+	}, databases.Capabilities{
+		Description:   "MongoDB, orders embed their order lines",
+		Transactions:  true,
+		FindAndModify: true,
+		Aggregate:     true,
+		Shard:         true,
+		Denormalized:  true,
+	})
+}
+
+// username and password override the credentials of the uri, tlsConfig replaces its TLS settings if not nil
+func NewMongoDb(uri string, dbname string, username string, password string, tlsConfig *tls.Config, transactions bool, findandmodify bool, aggregate bool, shard bool, shardZones bool, warehouses int) (*MongoDB, error) {
+	opts := options.Client().ApplyURI(uri)
+
+	if username != "" {
+		var cred options.Credential
+		if opts.Auth != nil {
+			// keep authSource and authMechanism of the uri
+			cred = *opts.Auth
+		}
+		cred.Username = username
+		cred.Password = password
+		cred.PasswordSet = password != ""
+		opts.SetAuth(cred)
+	}
+
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	client, err := mongo.NewClient(opts)
 
 	if err != nil {
 		return nil, err
@@ -50,14 +87,31 @@ func NewMongoDb(uri string, dbname string, transactions bool, findandmodify bool
 	return &MongoDB{
 		Client:        client,
 		C:             client.Database(dbname),
-		Aggregate:     false,
+		Aggregate:     aggregate,
 		transactions:  transactions,
 		findAndModify: findandmodify,
+		shard:         shard,
+		shardZones:    shardZones,
+		warehouses:    warehouses,
 		ctx:           mongo.NewSessionContext(context.Background(), session),
 	}, nil
 }
 
+func (db *MongoDB) ServerVersion(ctx context.Context) (string, error) {
+	var info struct {
+		Version string `bson:"version"`
+	}
+
+	err := db.Client.Database("admin").RunCommand(ctx, bson.D{{"buildInfo", 1}}).Decode(&info)
+
+	return info.Version, err
+}
+
 func (db *MongoDB) CreateSchema() error {
+	if db.shard {
+		return db.shardCollections(context.Background())
+	}
+
 	return nil
 }
 
@@ -91,6 +145,32 @@ func (db *MongoDB) RollbackTrx(ctx context.Context) error {
 	return nil
 }
 
+// WithTransaction runs fn in a transaction on a fresh session using the driver's callback API,
+// which retries on TransientTransactionError and UnknownTransactionCommitResult.
+func (db *MongoDB) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := db.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sctx)
+	})
+
+	return err
+}
+
+// sessionCtx returns ctx if it carries its own session (inside WithTransaction),
+// otherwise the session shared by StartTrx/CommitTrx/RollbackTrx.
+func (db *MongoDB) sessionCtx(ctx context.Context) context.Context {
+	if mongo.SessionFromContext(ctx) != nil {
+		return ctx
+	}
+
+	return db.ctx
+}
+
 func (db *MongoDB) CreateIndexes() error {
 	ascending := bsonx.Int32(1)
 	descending := bsonx.Int32(-1)
@@ -244,7 +324,7 @@ func (db *MongoDB) CreateIndexes() error {
 
 func (db *MongoDB) InsertOne(ctx context.Context, tableName string, d interface{}) error {
 	collection := db.C.Collection(tableName)
-	_, err := collection.InsertOne(db.ctx, d)
+	_, err := collection.InsertOne(db.sessionCtx(ctx), d)
 	if err != nil {
 		return err
 	}
@@ -254,7 +334,7 @@ func (db *MongoDB) InsertOne(ctx context.Context, tableName string, d interface{
 
 func (db *MongoDB) InsertBatch(ctx context.Context, tableName string, d []interface{}) error {
 	collection := db.C.Collection(tableName)
-	_, err := collection.InsertMany(db.ctx, d)
+	_, err := collection.InsertMany(db.sessionCtx(ctx), d)
 	if err != nil {
 		return err
 	}
@@ -275,7 +355,7 @@ func (db *MongoDB) IncrementDistrictOrderId(ctx context.Context, warehouseId int
 		}},
 	}
 
-	u, err := db.C.Collection("DISTRICT").UpdateOne(db.ctx, filter, update, nil)
+	u, err := db.C.Collection("DISTRICT").UpdateOne(db.sessionCtx(ctx), filter, update, nil)
 
 	if err != nil {
 		return err
@@ -313,7 +393,7 @@ func (db *MongoDB) GetNewOrder(ctx context.Context, warehouseId int, districtId
 
 	if db.findAndModify {
 		err = db.C.Collection("NEW_ORDER").FindOneAndDelete(
-			db.ctx,
+			db.sessionCtx(ctx),
 			filter,
 			options.FindOneAndDelete().SetSort(newOrderSort).SetProjection(newOrderProjection),
 		).Decode(&NewOrder)
@@ -323,7 +403,7 @@ func (db *MongoDB) GetNewOrder(ctx context.Context, warehouseId int, districtId
 		}
 	} else {
 		err = db.C.Collection("NEW_ORDER").FindOne(
-			db.ctx,
+			db.sessionCtx(ctx),
 			filter,
 			options.FindOne().SetProjection(newOrderProjection).SetSort(newOrderSort),
 		).Decode(&NewOrder)
@@ -345,7 +425,7 @@ func (db *MongoDB) DeleteNewOrder(ctx context.Context, orderId int, warehouseId
 		return nil
 	}
 
-	r, err := db.C.Collection("NEW_ORDER").DeleteOne(db.ctx, filter, nil)
+	r, err := db.C.Collection("NEW_ORDER").DeleteOne(db.sessionCtx(ctx), filter, nil)
 
 	if err != nil {
 		return err
@@ -363,7 +443,7 @@ func (db *MongoDB) GetCustomer(ctx context.Context, customerId int, warehouseId
 
 	var c models.Customer
 
-	err = db.C.Collection("CUSTOMER").FindOne(db.ctx, bson.D{
+	err = db.C.Collection("CUSTOMER").FindOne(db.sessionCtx(ctx), bson.D{
 		{"C_ID", customerId},
 		{"C_D_ID", districtId},
 		{"C_W_ID", warehouseId},
@@ -388,7 +468,7 @@ func (db *MongoDB) GetCustomerIdOrder(ctx context.Context, orderId int, warehous
 
 	var doc bson.M
 	err = db.C.Collection("ORDERS").FindOne(
-		db.ctx,
+		db.sessionCtx(ctx),
 		filter,
 		options.FindOne().SetProjection(bson.D{
 			{"_id", 0},
@@ -411,7 +491,7 @@ func (db *MongoDB) UpdateOrders(ctx context.Context, orderId int, warehouseId in
 		{"O_W_ID", warehouseId},
 	}
 
-	r, err := db.C.Collection("ORDERS").UpdateOne(db.ctx,
+	r, err := db.C.Collection("ORDERS").UpdateOne(db.sessionCtx(ctx),
 		filter,
 		bson.D{
 			{"$set", bson.D{
@@ -432,6 +512,34 @@ func (db *MongoDB) UpdateOrders(ctx context.Context, orderId int, warehouseId in
 }
 
 func (db *MongoDB) SumOLAmount(ctx context.Context, orderId int, warehouseId int, districtId int) (float64, error) {
+	if db.Aggregate {
+		return db.sumOLAmountAggregate(ctx, orderId, warehouseId, districtId)
+	}
+
+	var order models.Order
+
+	err := db.C.Collection("ORDERS").FindOne(db.sessionCtx(ctx), bson.D{
+		{"O_ID", orderId},
+		{"O_D_ID", districtId},
+		{"O_W_ID", warehouseId},
+	}, options.FindOne().SetProjection(bson.D{
+		{"_id", 0},
+		{"ORDER_LINE.OL_AMOUNT", 1},
+	})).Decode(&order)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var sum float64
+	for _, ol := range order.ORDER_LINE {
+		sum += ol.OL_AMOUNT
+	}
+
+	return sum, nil
+}
+
+func (db *MongoDB) sumOLAmountAggregate(ctx context.Context, orderId int, warehouseId int, districtId int) (float64, error) {
 	var err error
 
 	match := bson.D{
@@ -455,13 +563,18 @@ func (db *MongoDB) SumOLAmount(ctx context.Context, orderId int, warehouseId int
 		}},
 	}
 
-	cursor, err := db.C.Collection("ORDERS").Aggregate(db.ctx, mongo.Pipeline{match, unwind, group})
-	defer cursor.Close(db.ctx)
+	cursor, err := db.C.Collection("ORDERS").Aggregate(db.sessionCtx(ctx), mongo.Pipeline{match, unwind, group})
 	if err != nil {
 		return 0, err
 	}
+	defer cursor.Close(db.sessionCtx(ctx))
 
-	cursor.Next(db.ctx)
+	if !cursor.Next(db.sessionCtx(ctx)) {
+		if cursor.Err() != nil {
+			return 0, cursor.Err()
+		}
+		return 0, fmt.Errorf("no order lines found")
+	}
 
 	var agg bson.M
 	err = cursor.Decode(&agg)
@@ -476,7 +589,7 @@ func (db *MongoDB) SumOLAmount(ctx context.Context, orderId int, warehouseId int
 func (db *MongoDB) UpdateCustomer(ctx context.Context, customerId int, warehouseId int, districtId int, sumOlTotal float64) error {
 	var err error
 
-	r, err := db.C.Collection("CUSTOMER").UpdateOne(db.ctx,
+	r, err := db.C.Collection("CUSTOMER").UpdateOne(db.sessionCtx(ctx),
 		bson.D{
 			{"C_ID", customerId},
 			{"C_D_ID", districtId},
@@ -510,7 +623,7 @@ func (db *MongoDB) GetNextOrderId(ctx context.Context, warehouseId int, district
 	}
 
 	err := db.C.Collection("DISTRICT").FindOne(
-		db.ctx,
+		db.sessionCtx(ctx),
 		query,
 		options.FindOne().SetProjection(bson.D{
 			{"_id", 0},
@@ -525,8 +638,11 @@ func (db *MongoDB) GetNextOrderId(ctx context.Context, warehouseId int, district
 }
 
 func (db *MongoDB) GetStockCount(ctx context.Context, orderIdLt int, orderIdGt int, threshold int, warehouseId int, districtId int) (int64, error) {
+	if db.Aggregate {
+		return db.getStockCountAggregate(ctx, orderIdLt, orderIdGt, threshold, warehouseId, districtId)
+	}
 
-	cursor, err := db.C.Collection("ORDERS").Find(db.ctx,
+	cursor, err := db.C.Collection("ORDERS").Find(db.sessionCtx(ctx),
 		bson.D{
 			{"O_W_ID", warehouseId},
 			{"O_D_ID", districtId},
@@ -542,10 +658,10 @@ func (db *MongoDB) GetStockCount(ctx context.Context, orderIdLt int, orderIdGt i
 		return 0, err
 	}
 
-	defer cursor.Close(db.ctx)
+	defer cursor.Close(db.sessionCtx(ctx))
 	var orderIds []int32
 
-	for cursor.Next(db.ctx) {
+	for cursor.Next(db.sessionCtx(ctx)) {
 		var order bson.M
 		if err = cursor.Decode(&order); err != nil {
 			return 0, err
@@ -556,7 +672,7 @@ func (db *MongoDB) GetStockCount(ctx context.Context, orderIdLt int, orderIdGt i
 		}
 	}
 
-	c, err := db.C.Collection("STOCK").CountDocuments(db.ctx, bson.D{
+	c, err := db.C.Collection("STOCK").CountDocuments(db.sessionCtx(ctx), bson.D{
 		{"S_W_ID", warehouseId},
 		{"S_I_ID", bson.D{
 			{"$in", orderIds},
@@ -587,7 +703,7 @@ func (db *MongoDB) GetCustomerById(ctx context.Context, customerId int, warehous
 		{"C_BALANCE", 1},
 	}
 
-	err = db.C.Collection("CUSTOMER").FindOne(db.ctx, bson.D{
+	err = db.C.Collection("CUSTOMER").FindOne(db.sessionCtx(ctx), bson.D{
 		{"C_W_ID", warehouseId},
 		{"C_D_ID", districtId},
 		{"C_ID", customerId},
@@ -601,6 +717,9 @@ func (db *MongoDB) GetCustomerById(ctx context.Context, customerId int, warehous
 }
 
 func (db *MongoDB) GetCustomerByName(ctx context.Context, name string, warehouseId int, districtId int) (*models.Customer, error) {
+	if db.Aggregate {
+		return db.getCustomerByNameAggregate(ctx, name, warehouseId, districtId)
+	}
 
 	var customer models.Customer
 
@@ -613,20 +732,20 @@ func (db *MongoDB) GetCustomerByName(ctx context.Context, name string, warehouse
 		{"C_BALANCE", 1},
 	}
 
-	cursor, err := db.C.Collection("CUSTOMER").Find(db.ctx, bson.D{
+	cursor, err := db.C.Collection("CUSTOMER").Find(db.sessionCtx(ctx), bson.D{
 		{"C_W_ID", warehouseId},
 		{"C_D_ID", districtId},
 		{"C_LAST", name},
 	}, options.Find().SetProjection(projection))
 
-	defer cursor.Close(db.ctx)
+	defer cursor.Close(db.sessionCtx(ctx))
 
 	if err != nil {
 		return nil, err
 	}
 
 	var customers []models.Customer
-	err = cursor.All(db.ctx, &customers)
+	err = cursor.All(db.sessionCtx(ctx), &customers)
 
 	if err != nil {
 		return nil, err
@@ -642,6 +761,156 @@ func (db *MongoDB) GetCustomerByName(ctx context.Context, name string, warehouse
 	return &customer, nil
 }
 
+// getStockCountAggregate counts the distinct items of the last orders with low stock
+// in one pipeline, unwinding ORDER_LINE and joining STOCK with $lookup
+func (db *MongoDB) getStockCountAggregate(ctx context.Context, orderIdLt int, orderIdGt int, threshold int, warehouseId int, districtId int) (int64, error) {
+	match := bson.D{
+		{"$match", bson.D{
+			{"O_W_ID", warehouseId},
+			{"O_D_ID", districtId},
+			{"O_ID", bson.D{
+				{"$lt", orderIdLt},
+				{"$gte", orderIdGt},
+			}},
+		}},
+	}
+
+	unwind := bson.D{
+		{"$unwind", "$ORDER_LINE"},
+	}
+
+	group := bson.D{
+		{"$group", bson.D{
+			{"_id", "$ORDER_LINE.OL_I_ID"},
+		}},
+	}
+
+	lookup := bson.D{
+		{"$lookup", bson.D{
+			{"from", "STOCK"},
+			{"let", bson.D{{"i_id", "$_id"}}},
+			{"pipeline", mongo.Pipeline{
+				{{"$match", bson.D{
+					{"$expr", bson.D{
+						{"$and", bson.A{
+							bson.D{{"$eq", bson.A{"$S_W_ID", warehouseId}}},
+							bson.D{{"$eq", bson.A{"$S_I_ID", "$$i_id"}}},
+							bson.D{{"$lt", bson.A{"$S_QUANTITY", threshold}}},
+						}},
+					}},
+				}}},
+				{{"$project", bson.D{{"_id", 1}}}},
+			}},
+			{"as", "stock"},
+		}},
+	}
+
+	lowStock := bson.D{
+		{"$match", bson.D{
+			{"stock", bson.D{{"$ne", bson.A{}}}},
+		}},
+	}
+
+	count := bson.D{
+		{"$count", "count"},
+	}
+
+	cursor, err := db.C.Collection("ORDERS").Aggregate(db.sessionCtx(ctx),
+		mongo.Pipeline{match, unwind, group, lookup, lowStock, count},
+		options.Aggregate().SetComment("STOCK_LEVEL"))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(db.sessionCtx(ctx))
+
+	// $count returns no document at all when nothing matched
+	if !cursor.Next(db.sessionCtx(ctx)) {
+		return 0, cursor.Err()
+	}
+
+	var agg struct {
+		Count int64 `bson:"count"`
+	}
+	err = cursor.Decode(&agg)
+	if err != nil {
+		return 0, err
+	}
+
+	return agg.Count, nil
+}
+
+// getCustomerByNameAggregate picks the customer in the middle of the C_FIRST ordered list server side
+func (db *MongoDB) getCustomerByNameAggregate(ctx context.Context, name string, warehouseId int, districtId int) (*models.Customer, error) {
+	match := bson.D{
+		{"$match", bson.D{
+			{"C_W_ID", warehouseId},
+			{"C_D_ID", districtId},
+			{"C_LAST", name},
+		}},
+	}
+
+	sort := bson.D{
+		{"$sort", bson.D{{"C_FIRST", 1}}},
+	}
+
+	group := bson.D{
+		{"$group", bson.D{
+			{"_id", nil},
+			{"customers", bson.D{
+				{"$push", bson.D{
+					{"C_ID", "$C_ID"},
+					{"C_FIRST", "$C_FIRST"},
+					{"C_MIDDLE", "$C_MIDDLE"},
+					{"C_LAST", "$C_LAST"},
+					{"C_BALANCE", "$C_BALANCE"},
+				}},
+			}},
+		}},
+	}
+
+	middle := bson.D{
+		{"$project", bson.D{
+			{"_id", 0},
+			{"customer", bson.D{
+				{"$arrayElemAt", bson.A{
+					"$customers",
+					bson.D{{"$toInt", bson.D{
+						{"$floor", bson.D{
+							{"$divide", bson.A{
+								bson.D{{"$subtract", bson.A{bson.D{{"$size", "$customers"}}, 1}}},
+								2,
+							}},
+						}},
+					}}},
+				}},
+			}},
+		}},
+	}
+
+	cursor, err := db.C.Collection("CUSTOMER").Aggregate(db.sessionCtx(ctx), mongo.Pipeline{match, sort, group, middle})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(db.sessionCtx(ctx))
+
+	if !cursor.Next(db.sessionCtx(ctx)) {
+		if cursor.Err() != nil {
+			return nil, cursor.Err()
+		}
+		return nil, fmt.Errorf("No customer found")
+	}
+
+	var agg struct {
+		Customer models.Customer `bson:"customer"`
+	}
+	err = cursor.Decode(&agg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &agg.Customer, nil
+}
+
 func (db *MongoDB) GetLastOrder(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Order, error) {
 	var err error
 	var order models.Order
@@ -654,7 +923,7 @@ func (db *MongoDB) GetLastOrder(ctx context.Context, customerId int, warehouseId
 
 	sort := bson.D{{"O_ID", 1}}
 
-	err = db.C.Collection("ORDERS").FindOne(db.ctx, bson.D{
+	err = db.C.Collection("ORDERS").FindOne(db.sessionCtx(ctx), bson.D{
 		{"O_W_ID", warehouseId},
 		{"O_D_ID", districtId},
 		{"O_C_ID", customerId},
@@ -676,7 +945,7 @@ func (db *MongoDB) GetOrderLines(ctx context.Context, orderId int, warehouseId i
 		{"ORDER_LINE", 1},
 	}
 
-	err = db.C.Collection("ORDERS").FindOne(db.ctx, bson.D{
+	err = db.C.Collection("ORDERS").FindOne(db.sessionCtx(ctx), bson.D{
 		{"O_W_ID", warehouseId},
 		{"O_D_ID", districtId},
 		{"O_ID", orderId},
@@ -705,7 +974,7 @@ func (db *MongoDB) GetWarehouse(ctx context.Context, warehouseId int) (*models.W
 
 	var warehouse models.Warehouse
 
-	err = db.C.Collection("WAREHOUSE").FindOne(db.ctx, bson.D{
+	err = db.C.Collection("WAREHOUSE").FindOne(db.sessionCtx(ctx), bson.D{
 		{"W_ID", warehouseId},
 	},
 		options.FindOne().SetProjection(warehouseProjection),
@@ -720,7 +989,7 @@ func (db *MongoDB) GetWarehouse(ctx context.Context, warehouseId int) (*models.W
 
 func (db *MongoDB) UpdateWarehouseBalance(ctx context.Context, warehouseId int, amount float64) error {
 
-	r, err := db.C.Collection("WAREHOUSE").UpdateOne(db.ctx, bson.D{
+	r, err := db.C.Collection("WAREHOUSE").UpdateOne(db.sessionCtx(ctx), bson.D{
 		{"W_ID", warehouseId},
 	},
 		bson.D{
@@ -746,7 +1015,7 @@ func (db *MongoDB) GetDistrict(ctx context.Context, warehouseId int, districtId
 
 	var district models.District
 
-	err = db.C.Collection("DISTRICT").FindOne(db.ctx, bson.D{
+	err = db.C.Collection("DISTRICT").FindOne(db.sessionCtx(ctx), bson.D{
 		{"D_ID", districtId},
 		{"D_W_ID", warehouseId},
 	}).Decode(&district)
@@ -770,7 +1039,7 @@ func (db *MongoDB) UpdateDistrictBalance(ctx context.Context, warehouseId int, d
 		}},
 	}
 
-	r, err := db.C.Collection("DISTRICT").UpdateOne(db.ctx, filter, update, nil)
+	r, err := db.C.Collection("DISTRICT").UpdateOne(db.sessionCtx(ctx), filter, update, nil)
 
 	if r.MatchedCount == 0 {
 		return fmt.Errorf("No district found")
@@ -791,7 +1060,7 @@ func (db *MongoDB) InsertHistory(ctx context.Context,
 	data string,
 ) error {
 
-	_, err := db.C.Collection("HISTORY").InsertOne(db.ctx, bson.D{
+	_, err := db.C.Collection("HISTORY").InsertOne(db.sessionCtx(ctx), bson.D{
 		{"H_D_ID", districtId},
 		{"H_W_ID", warehouseId},
 		{"H_C_W_ID", warehouseId},
@@ -821,7 +1090,7 @@ func (db *MongoDB) UpdateCredit(ctx context.Context, customerId int, warehouseId
 		}})
 	}
 
-	_, err = db.C.Collection("CUSTOMER").UpdateOne(db.ctx,
+	_, err = db.C.Collection("CUSTOMER").UpdateOne(db.sessionCtx(ctx),
 		bson.D{
 			{"C_ID", customerId},
 			{"C_W_ID", warehouseId},
@@ -860,7 +1129,7 @@ func (db *MongoDB) CreateOrder(ctx context.Context,
 		ORDER_LINE:   orderLine,
 	}
 
-	_, err := db.C.Collection("NEW_ORDER").InsertOne(db.ctx,
+	_, err := db.C.Collection("NEW_ORDER").InsertOne(db.sessionCtx(ctx),
 		bson.D{
 			{"NO_O_ID", orderId},
 			{"NO_D_ID", districtId},
@@ -871,7 +1140,7 @@ func (db *MongoDB) CreateOrder(ctx context.Context,
 		return err
 	}
 
-	_, err = db.C.Collection("ORDERS").InsertOne(db.ctx, order)
+	_, err = db.C.Collection("ORDERS").InsertOne(db.sessionCtx(ctx), order)
 
 	if err != nil {
 		return nil
@@ -883,7 +1152,7 @@ func (db *MongoDB) CreateOrder(ctx context.Context,
 //todo: sharding
 func (db *MongoDB) GetItems(ctx context.Context, itemIds []int) (*[]models.Item, error) {
 
-	cursor, err := db.C.Collection("ITEM").Find(db.ctx, bson.D{
+	cursor, err := db.C.Collection("ITEM").Find(db.sessionCtx(ctx), bson.D{
 		{"I_ID", bson.D{
 			{"$in", itemIds},
 		}}},
@@ -901,7 +1170,7 @@ func (db *MongoDB) GetItems(ctx context.Context, itemIds []int) (*[]models.Item,
 	}
 
 	var items []models.Item
-	err = cursor.All(db.ctx, &items)
+	err = cursor.All(db.sessionCtx(ctx), &items)
 
 	if err != nil {
 		return nil, err
@@ -927,7 +1196,7 @@ func (db *MongoDB) GetStockInfo(ctx context.Context, districtId int, iIds []int,
 
 	var cursor *mongo.Cursor
 	if allLocal == 1 {
-		cursor, err = db.C.Collection("STOCK").Find(db.ctx, bson.D{
+		cursor, err = db.C.Collection("STOCK").Find(db.sessionCtx(ctx), bson.D{
 			{"S_I_ID", bson.D{
 				{"$in", iIds},
 			}},
@@ -946,7 +1215,7 @@ func (db *MongoDB) GetStockInfo(ctx context.Context, districtId int, iIds []int,
 			})
 		}
 
-		cursor, err = db.C.Collection("STOCK").Find(db.ctx,
+		cursor, err = db.C.Collection("STOCK").Find(db.sessionCtx(ctx),
 			bson.D{
 				{"$or", searchList},
 			}, options.Find().SetProjection(stockProjection))
@@ -958,7 +1227,7 @@ func (db *MongoDB) GetStockInfo(ctx context.Context, districtId int, iIds []int,
 
 	var stocks []models.Stock
 
-	err = cursor.All(db.ctx, &stocks)
+	err = cursor.All(db.sessionCtx(ctx), &stocks)
 	if err != nil {
 		return nil, err
 	}
@@ -967,7 +1236,7 @@ func (db *MongoDB) GetStockInfo(ctx context.Context, districtId int, iIds []int,
 }
 
 func (db *MongoDB) UpdateStock(ctx context.Context, stockId int, warehouseId int, quantity int, ytd int, ordercnt int, remotecnt int) error {
-	ru, err := db.C.Collection("STOCK").UpdateOne(db.ctx,
+	ru, err := db.C.Collection("STOCK").UpdateOne(db.sessionCtx(ctx),
 		bson.D{
 			{"S_I_ID", stockId},
 			{"S_W_ID", warehouseId},
@@ -992,3 +1261,81 @@ func (db *MongoDB) UpdateStock(ctx context.Context, stockId int, warehouseId int
 
 	return nil
 }
+
+// IncrementDistrictOrderIdAndGet increments D_NEXT_O_ID and returns the district as it is after the update,
+// so the order id taken by New-Order is D_NEXT_O_ID-1. Used when findAndModify is set.
+func (db *MongoDB) IncrementDistrictOrderIdAndGet(ctx context.Context, warehouseId int, districtId int) (*models.District, error) {
+	var district models.District
+
+	err := db.C.Collection("DISTRICT").FindOneAndUpdate(db.sessionCtx(ctx),
+		bson.D{
+			{"D_ID", districtId},
+			{"D_W_ID", warehouseId},
+		},
+		bson.D{
+			{"$inc", bson.D{
+				{"D_NEXT_O_ID", 1},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&district)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &district, nil
+}
+
+// UpdateStockAndGet applies the New-Order stock update server side and returns the updated stock,
+// replacing the GetStockInfo/UpdateStock round trips when findAndModify is set.
+func (db *MongoDB) UpdateStockAndGet(ctx context.Context, districtId int, stockId int, warehouseId int, quantity int, remote bool) (*models.Stock, error) {
+	var stock models.Stock
+
+	remoteCnt := 0
+	if remote {
+		remoteCnt = 1
+	}
+
+	stockProjection := bson.D{
+		{"_id", 0},
+		{"S_I_ID", 1},
+		{"S_W_ID", 1},
+		{"S_QUANTITY", 1},
+		{"S_DATA", 1},
+		{"S_YTD", 1},
+		{"S_ORDER_CNT", 1},
+		{"S_REMOTE_CNT", 1},
+		{fmt.Sprintf("S_DIST_%02d", districtId), 1},
+	}
+
+	update := mongo.Pipeline{
+		{{"$set", bson.D{
+			{"S_QUANTITY", bson.D{
+				{"$cond", bson.A{
+					bson.D{{"$gte", bson.A{"$S_QUANTITY", quantity + 10}}},
+					bson.D{{"$subtract", bson.A{"$S_QUANTITY", quantity}}},
+					bson.D{{"$add", bson.A{"$S_QUANTITY", 91 - quantity}}},
+				}},
+			}},
+			{"S_YTD", bson.D{{"$add", bson.A{"$S_YTD", quantity}}}},
+			{"S_ORDER_CNT", bson.D{{"$add", bson.A{"$S_ORDER_CNT", 1}}}},
+			{"S_REMOTE_CNT", bson.D{{"$add", bson.A{"$S_REMOTE_CNT", remoteCnt}}}},
+		}}},
+	}
+
+	err := db.C.Collection("STOCK").FindOneAndUpdate(db.sessionCtx(ctx),
+		bson.D{
+			{"S_I_ID", stockId},
+			{"S_W_ID", warehouseId},
+		},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(stockProjection),
+	).Decode(&stock)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &stock, nil
+}
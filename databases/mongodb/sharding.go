@@ -0,0 +1,149 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type shardKey struct {
+	collection string
+	// first field is always the warehouse id
+	fields []string
+}
+
+// ITEM has no warehouse id and stays unsharded on the primary shard,
+// ORDER_LINE is embedded in ORDERS for MongoDB
+var shardKeys = []shardKey{
+	{"WAREHOUSE", []string{"W_ID"}},
+	{"DISTRICT", []string{"D_W_ID", "D_ID"}},
+	{"CUSTOMER", []string{"C_W_ID", "C_D_ID", "C_ID"}},
+	{"HISTORY", []string{"H_W_ID"}},
+	{"STOCK", []string{"S_W_ID", "S_I_ID"}},
+	{"ORDERS", []string{"O_W_ID", "O_D_ID", "O_ID"}},
+	{"NEW_ORDER", []string{"NO_W_ID", "NO_D_ID", "NO_O_ID"}},
+}
+
+// boundary returns the shard key document {<W_ID field>: w, <other fields>: MinKey}
+func (k shardKey) boundary(w interface{}) bson.D {
+	d := bson.D{{k.fields[0], w}}
+	for _, f := range k.fields[1:] {
+		d = append(d, bson.E{f, primitive.MinKey{}})
+	}
+
+	return d
+}
+
+func (k shardKey) keyPattern() bson.D {
+	var d bson.D
+	for _, f := range k.fields {
+		d = append(d, bson.E{f, 1})
+	}
+
+	return d
+}
+
+// shardCollections enables sharding on the database, shards every collection on its
+// warehouse-prefixed key and pre-splits one chunk per warehouse. It has to run through mongos.
+func (db *MongoDB) shardCollections(ctx context.Context) error {
+	admin := db.Client.Database("admin")
+
+	err := admin.RunCommand(ctx, bson.D{{"enableSharding", db.C.Name()}}).Err()
+	if err != nil {
+		return fmt.Errorf("enableSharding: %w", err)
+	}
+
+	for _, k := range shardKeys {
+		ns := db.C.Name() + "." + k.collection
+
+		err = admin.RunCommand(ctx, bson.D{
+			{"shardCollection", ns},
+			{"key", k.keyPattern()},
+		}).Err()
+		if err != nil {
+			return fmt.Errorf("shardCollection %s: %w", ns, err)
+		}
+
+		for w := 2; w <= db.warehouses; w++ {
+			err = admin.RunCommand(ctx, bson.D{
+				{"split", ns},
+				{"middle", k.boundary(w)},
+			}).Err()
+			if err != nil {
+				return fmt.Errorf("split %s at warehouse %d: %w", ns, w, err)
+			}
+		}
+	}
+
+	if db.shardZones {
+		return db.createZones(ctx)
+	}
+
+	return nil
+}
+
+// createZones creates one zone per shard and assigns each zone a contiguous range of warehouses,
+// so the balancer moves the pre-split chunks of a warehouse to the same shard
+func (db *MongoDB) createZones(ctx context.Context) error {
+	admin := db.Client.Database("admin")
+
+	var r struct {
+		Shards []struct {
+			ID string `bson:"_id"`
+		} `bson:"shards"`
+	}
+
+	err := admin.RunCommand(ctx, bson.D{{"listShards", 1}}).Decode(&r)
+	if err != nil {
+		return fmt.Errorf("listShards: %w", err)
+	}
+
+	if len(r.Shards) == 0 {
+		return fmt.Errorf("no shards found")
+	}
+
+	perShard := (db.warehouses + len(r.Shards) - 1) / len(r.Shards)
+
+	for i, shard := range r.Shards {
+		lo := i*perShard + 1
+		if lo > db.warehouses {
+			break
+		}
+
+		zone := shard.ID
+
+		err = admin.RunCommand(ctx, bson.D{
+			{"addShardToZone", shard.ID},
+			{"zone", zone},
+		}).Err()
+		if err != nil {
+			return fmt.Errorf("addShardToZone %s: %w", shard.ID, err)
+		}
+
+		var min, max interface{} = lo, lo + perShard
+		if i == 0 {
+			min = primitive.MinKey{}
+		}
+		if lo+perShard > db.warehouses {
+			max = primitive.MaxKey{}
+		}
+
+		for _, k := range shardKeys {
+			ns := db.C.Name() + "." + k.collection
+
+			err = admin.RunCommand(ctx, bson.D{
+				{"updateZoneKeyRange", ns},
+				{"min", k.boundary(min)},
+				{"max", k.boundary(max)},
+				{"zone", zone},
+			}).Err()
+			if err != nil {
+				return fmt.Errorf("updateZoneKeyRange %s for zone %s: %w", ns, zone, err)
+			}
+		}
+	}
+
+	return nil
+}
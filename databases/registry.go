@@ -0,0 +1,152 @@
+package databases
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Options are the settings of a connection, every driver reads the ones it understands
+type Options struct {
+	URI           string
+	DBName        string
+	Username      string
+	Password      string
+	TLS           *tls.Config // nil unless a TLS flag is given, see TLSConfig
+	Transactions  bool
+	FindAndModify bool
+	Aggregate     bool
+	Shard         bool
+	ShardZones    bool
+	WareHouses    int
+	Debug         bool
+	// Params are the settings of a single driver by name, e.g. es-refresh, set with --driver-opt name=value.
+	// A driver registered from outside this repository reads its own ones from here.
+	Params map[string]string
+}
+
+// Param returns the driver parameter name, def if it is not set
+func (o Options) Param(name string, def string) string {
+	if v, ok := o.Params[name]; ok {
+		return v
+	}
+
+	return def
+}
+
+// BoolParam returns the driver parameter name as a bool, def if it is not set
+func (o Options) BoolParam(name string, def bool) (bool, error) {
+	v, ok := o.Params[name]
+	if !ok {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("driver parameter %s: %q is not a bool", name, v)
+	}
+
+	return b, nil
+}
+
+// IntParam returns the driver parameter name as an int, def if it is not set
+func (o Options) IntParam(name string, def int) (int, error) {
+	v, ok := o.Params[name]
+	if !ok {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("driver parameter %s: %q is not an int", name, v)
+	}
+
+	return i, nil
+}
+
+// Factory opens a connection of a driver
+type Factory func(o Options) (Database, error)
+
+// Capabilities describe what a driver supports
+type Capabilities struct {
+	Description string
+	// Transactions is true if --trx runs the transactions atomically
+	Transactions bool
+	// FindAndModify is true if IncrementDistrictOrderIdAndGet and UpdateStockAndGet are implemented
+	FindAndModify bool
+	// Aggregate is true if --aggregate is supported
+	Aggregate bool
+	// Shard is true if --shard and --shard-zones are supported
+	Shard bool
+	// Denormalized is true if orders embed their order lines instead of loading them into ORDER_LINE
+	Denormalized bool
+	// InProcess is true if the data lives in the go-tpcc process, run has to load it itself
+	InProcess bool
+}
+
+type driver struct {
+	factory Factory
+	caps    Capabilities
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]driver{}
+)
+
+// Register makes a driver available under name. It is meant to be called from the init function of the driver
+// package, so importing the package is enough to use the driver. Registering a name twice panics.
+func Register(name string, factory Factory, caps Capabilities) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if factory == nil {
+		panic("databases: Register factory is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("databases: Register called twice for driver " + name)
+	}
+
+	drivers[name] = driver{
+		factory: factory,
+		caps:    caps,
+	}
+}
+
+// Drivers returns the names of the registered drivers, sorted
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	var names []string
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Lookup returns the capabilities of a registered driver
+func Lookup(name string) (Capabilities, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	d, ok := drivers[name]
+	return d.caps, ok
+}
+
+// NewDatabase opens a connection with the registered driver name
+func NewDatabase(name string, o Options) (Database, error) {
+	driversMu.RLock()
+	d, ok := drivers[name]
+	driversMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown database driver %q, the binary supports %v", name, Drivers())
+	}
+
+	return d.factory(o)
+}
@@ -1,6 +1,7 @@
-package mysql
+package sqldb
 
-func (db *MySQL) CreateSchema() error {
+// CreateSchema creates the tables, the column types are MySQL ones translated by the dialect
+func (db *DB) CreateSchema() error {
 
 	tables := []string{`
 CREATE TABLE IF NOT EXISTS WAREHOUSE (
@@ -33,7 +34,7 @@ CREATE TABLE STOCK (
   S_REMOTE_CNT smallint DEFAULT NULL,
   S_DATA varchar(50) DEFAULT NULL,
   PRIMARY KEY (S_W_ID,S_I_ID))
-`,`
+`, `
 CREATE TABLE ORDERS (
   O_ID int NOT NULL,
   O_D_ID tinyint NOT NULL,
@@ -45,7 +46,7 @@ CREATE TABLE ORDERS (
   O_ALL_LOCAL tinyint DEFAULT NULL,
   PRIMARY KEY (O_W_ID,O_D_ID,O_ID)
  )
-`,`
+`, `
 CREATE TABLE ORDER_LINE (
   OL_O_ID int NOT NULL,
   OL_D_ID tinyint NOT NULL,
@@ -58,13 +59,13 @@ CREATE TABLE ORDER_LINE (
   OL_AMOUNT decimal(6,2) DEFAULT NULL,
   OL_DIST_INFO char(24) DEFAULT NULL,
   PRIMARY KEY (OL_W_ID,OL_D_ID,OL_O_ID,OL_NUMBER))
-`,`
+`, `
  CREATE TABLE NEW_ORDER (
   NO_O_ID int NOT NULL,
   NO_D_ID tinyint NOT NULL,
   NO_W_ID smallint NOT NULL,
   PRIMARY KEY (NO_W_ID,NO_D_ID,NO_O_ID))
-`,`
+`, `
 CREATE TABLE ITEM (
   I_ID int NOT NULL,
   I_IM_ID int DEFAULT NULL,
@@ -73,7 +74,7 @@ CREATE TABLE ITEM (
   I_DATA varchar(50) DEFAULT NULL,
   PRIMARY KEY (I_ID))
 `,
-`
+		`
 CREATE TABLE HISTORY (
   H_C_ID int DEFAULT NULL,
   H_C_D_ID tinyint DEFAULT NULL,
@@ -97,7 +98,7 @@ CREATE TABLE DISTRICT (
   D_YTD decimal(12,2) DEFAULT NULL,
   D_NEXT_O_ID int DEFAULT NULL,
   PRIMARY KEY (D_W_ID,D_ID))
-`,`
+`, `
  CREATE TABLE CUSTOMER (
   C_ID int NOT NULL,
   C_D_ID tinyint NOT NULL,
@@ -123,7 +124,7 @@ CREATE TABLE DISTRICT (
   PRIMARY KEY (C_W_ID,C_D_ID,C_ID))
 `}
 	for _, table := range tables {
-		_, err := db.Client.Exec(table)
+		_, err := db.Client.Exec(db.dialect.columnTypes(table))
 		if err != nil {
 			return err
 		}
@@ -132,19 +133,19 @@ CREATE TABLE DISTRICT (
 	return nil
 }
 
-func (db *MySQL) CreateIndexes() error {
+func (db *DB) CreateIndexes() error {
 
-	queries := []string {
+	queries := []string{
 		"CREATE INDEX idx_customer on CUSTOMER (C_W_ID,C_D_ID,C_LAST,C_FIRST)",
 		"CREATE INDEX idx_orders  ON ORDERS  (O_W_ID,O_D_ID,O_C_ID,O_ID)",
 		"CREATE INDEX fkey_stock_2 ON STOCK (S_I_ID)",
 		"CREATE INDEX fkey_order_line_2 ON ORDER_LINE (OL_SUPPLY_W_ID,OL_I_ID)",
 		"CREATE INDEX fkey_history_1 ON HISTORY (H_C_W_ID,H_C_D_ID,H_C_ID)",
 		"CREATE INDEX fkey_history_2 ON HISTORY (H_W_ID,H_D_ID)",
-		}
+	}
 
-	if db.fk {
-		fkq := []string {
+	if db.dialect.ForeignKeys {
+		fkq := []string{
 			"ALTER TABLE NEW_ORDER ADD CONSTRAINT fkey_new_orders_1 FOREIGN KEY(NO_W_ID,NO_D_ID,NO_O_ID) REFERENCES ORDERS(O_W_ID,O_D_ID,O_ID)",
 			"ALTER TABLE ORDERS ADD CONSTRAINT fkey_orders_1 FOREIGN KEY(O_W_ID,O_D_ID,O_C_ID) REFERENCES CUSTOMER(C_W_ID,C_D_ID,C_ID)",
 			"ALTER TABLE CUSTOMER ADD CONSTRAINT fkey_customer_1 FOREIGN KEY(C_W_ID,C_D_ID) REFERENCES DISTRICT(D_W_ID,D_ID)",
@@ -159,6 +160,10 @@ func (db *MySQL) CreateIndexes() error {
 
 		queries = append(queries, fkq...)
 	}
+	if db.dialect.Analyze != "" {
+		queries = append(queries, db.dialect.Analyze)
+	}
+
 	for _, query := range queries {
 		_, err := db.Client.Exec(query)
 		if err != nil {
@@ -167,4 +172,4 @@ func (db *MySQL) CreateIndexes() error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
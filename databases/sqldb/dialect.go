@@ -0,0 +1,76 @@
+package sqldb
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes what differs between the SQL databases driven through database/sql.
+// Queries are written with ? placeholders and the MySQL column types of the schema, the dialect rewrites both.
+// There is no upsert clause: CreateSchema drops the tables before loading and the transactions only insert new keys.
+type Dialect struct {
+	// Name is used in error messages
+	Name string
+	// Driver is the database/sql driver name
+	Driver string
+	// Placeholder returns the bind parameter for the n-th argument of a query, counting from 1.
+	// nil keeps ?
+	Placeholder func(n int) string
+	// Types replaces column types of the schema, e.g. datetime with timestamp
+	Types map[string]string
+	// ForUpdate locks the rows read by a transaction before they are updated, empty if the database has no row locks
+	ForUpdate string
+	// Returning reports whether UPDATE ... RETURNING is supported, it is used for --findandmodify
+	Returning bool
+	// ForeignKeys creates the foreign keys with the indexes, it needs ALTER TABLE ... ADD CONSTRAINT
+	ForeignKeys bool
+	// Analyze is run once the data is loaded and the indexes exist, empty to skip
+	Analyze string
+	// VersionQuery returns the version of the server in a single row and column
+	VersionQuery string
+	// Retryable reports whether err is a deadlock, serialization failure or lock timeout that goes away when the
+	// transaction is run again
+	Retryable func(err error) bool
+}
+
+// Dollar numbers the placeholders as $1, $2, ...
+func Dollar(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// rebind replaces the ? placeholders of query with those of the dialect
+func (d *Dialect) rebind(query string) string {
+	if d.Placeholder == nil {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString(d.Placeholder(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+var typeRe = regexp.MustCompile(`\b[a-z]+\b`)
+
+// columnTypes replaces the column types of a CREATE TABLE statement
+func (d *Dialect) columnTypes(ddl string) string {
+	if len(d.Types) == 0 {
+		return ddl
+	}
+
+	return typeRe.ReplaceAllStringFunc(ddl, func(t string) string {
+		if r, ok := d.Types[t]; ok {
+			return r
+		}
+		return t
+	})
+}
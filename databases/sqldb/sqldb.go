@@ -0,0 +1,933 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/tpcc/models"
+)
+
+// DB implements databases.Database on database/sql, everything that differs between databases is in its Dialect
+type DB struct {
+	dialect      *Dialect
+	transactions bool
+	Client       *sql.DB
+	tx           *sql.Tx
+	isTx         bool
+}
+
+// Open connects to dsn with the driver of the dialect. A worker uses a single connection.
+func Open(dialect *Dialect, dsn string, transactions bool) (*DB, error) {
+	db, err := sql.Open(dialect.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxIdleConns(1)
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(-1)
+
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{
+		dialect:      dialect,
+		transactions: transactions,
+		Client:       db,
+	}, nil
+}
+
+// ServerVersion runs the VersionQuery of the dialect
+func (db *DB) ServerVersion(ctx context.Context) (string, error) {
+	if db.dialect.VersionQuery == "" {
+		return "", nil
+	}
+
+	var version string
+	err := db.queryRow(db.dialect.VersionQuery).Scan(&version)
+
+	return version, err
+}
+
+// retryError marks an error the dialect considers transient, the executor runs the transaction again
+type retryError struct {
+	error
+}
+
+func (e retryError) Temporary() bool {
+	return true
+}
+
+func (e retryError) Unwrap() error {
+	return e.error
+}
+
+func (db *DB) wrap(err error) error {
+	if err != nil && db.dialect.Retryable != nil && db.dialect.Retryable(err) {
+		return retryError{err}
+	}
+
+	return err
+}
+
+// columns returns the column names and values of a row, skipping fields tagged with sql
+func columns(d interface{}) ([]string, []interface{}) {
+	v := reflect.ValueOf(d)
+	t := v.Type()
+	var fields []string
+	var values []interface{}
+
+	for i := 0; i < v.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("sql"); ok {
+			continue
+		}
+
+		fields = append(fields, t.Field(i).Name)
+		values = append(values, v.Field(i).Interface())
+	}
+
+	return fields, values
+}
+
+func insertQuery(tableName string, fields []string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", tableName, strings.Join(fields, ","), strings.Repeat(",?", len(fields))[1:])
+}
+
+func (db *DB) InsertOne(ctx context.Context, tableName string, d interface{}) error {
+	fields, values := columns(d)
+
+	_, err := db.exec(insertQuery(tableName, fields), values...)
+	return err
+}
+
+// InsertBatch inserts all rows in a single transaction with one prepared statement
+func (db *DB) InsertBatch(ctx context.Context, tableName string, d []interface{}) error {
+	if len(d) == 0 {
+		return nil
+	}
+
+	fields, _ := columns(d[0])
+
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return db.wrap(err)
+	}
+
+	stmt, err := tx.Prepare(db.dialect.rebind(insertQuery(tableName, fields)))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, item := range d {
+		_, values := columns(item)
+
+		_, err = stmt.Exec(values...)
+		if err != nil {
+			tx.Rollback()
+			return db.wrap(err)
+		}
+	}
+
+	return db.wrap(tx.Commit())
+}
+
+func (db *DB) StartTrx() error {
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return db.wrap(err)
+	}
+	db.tx = tx
+	db.isTx = true
+	return nil
+}
+
+func (db *DB) CommitTrx(ctx context.Context) error {
+	err := db.tx.Commit()
+	if err != nil {
+		return db.wrap(err)
+	}
+
+	db.isTx = false
+	return nil
+}
+
+func (db *DB) RollbackTrx(ctx context.Context) error {
+	err := db.tx.Rollback()
+	if err != nil {
+		return err
+	}
+
+	db.isTx = false
+	return nil
+}
+
+// WithTransaction runs fn between StartTrx and CommitTrx, rolling back if fn fails
+func (db *DB) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := db.StartTrx()
+	if err != nil {
+		return err
+	}
+
+	err = fn(ctx)
+	if err != nil {
+		rErr := db.RollbackTrx(ctx)
+		if rErr != nil {
+			return rErr
+		}
+		return err
+	}
+
+	return db.CommitTrx(ctx)
+}
+
+// forUpdate locks the rows selected by query when running in transactions
+func (db *DB) forUpdate(query string) string {
+	if db.transactions && db.dialect.ForUpdate != "" {
+		return query + " " + db.dialect.ForUpdate
+	}
+
+	return query
+}
+
+func (db *DB) query(query string, args ...interface{}) (*sql.Rows, error) {
+	query = db.dialect.rebind(query)
+
+	var rows *sql.Rows
+	var err error
+
+	if db.transactions && db.isTx {
+		rows, err = db.tx.Query(query, args...)
+	} else {
+		rows, err = db.Client.Query(query, args...)
+	}
+
+	return rows, db.wrap(err)
+}
+
+// row wraps the error of Scan like the other queries
+type row struct {
+	*sql.Row
+	db *DB
+}
+
+func (r row) Scan(dest ...interface{}) error {
+	return r.db.wrap(r.Row.Scan(dest...))
+}
+
+func (db *DB) queryRow(query string, args ...interface{}) row {
+	query = db.dialect.rebind(query)
+
+	if db.transactions && db.isTx {
+		return row{db.tx.QueryRow(query, args...), db}
+	}
+
+	return row{db.Client.QueryRow(query, args...), db}
+}
+
+func (db *DB) exec(query string, args ...interface{}) (sql.Result, error) {
+	query = db.dialect.rebind(query)
+
+	var r sql.Result
+	var err error
+
+	if db.transactions && db.isTx {
+		r, err = db.tx.Exec(query, args...)
+	} else {
+		r, err = db.Client.Exec(query, args...)
+	}
+
+	return r, db.wrap(err)
+}
+
+func (db *DB) IncrementDistrictOrderId(ctx context.Context, warehouseId int, districtId int) error {
+
+	query := "UPDATE DISTRICT SET D_NEXT_O_ID = D_NEXT_O_ID+? WHERE D_ID = ? AND D_W_ID = ?"
+
+	r, err := db.exec(query, 1, districtId, warehouseId)
+
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra == 0 {
+		return fmt.Errorf("unable to match district")
+	}
+
+	return nil
+}
+
+func (db *DB) CheckNewOrder(ctx context.Context, warehouseId int, districtId int) (*models.NewOrder, *string, error) {
+	return nil, nil, nil
+}
+
+func (db *DB) GetNewOrder(ctx context.Context, warehouseId int, districtId int) (*models.NewOrder, error) {
+	query := db.forUpdate("SELECT NO_O_ID FROM NEW_ORDER WHERE NO_D_ID = ? AND NO_W_ID = ? ORDER BY NO_O_ID ASC LIMIT 1")
+	r := db.queryRow(query, districtId, warehouseId)
+
+	var no models.NewOrder
+	err := r.Scan(&no.NO_O_ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &no, nil
+}
+func (db *DB) DeleteNewOrder(ctx context.Context, orderId int, warehouseId int, districtId int) error {
+
+	query := "DELETE FROM NEW_ORDER WHERE NO_O_ID = ? AND NO_D_ID = ? AND NO_W_ID = ?"
+	r, err := db.exec(query, orderId, districtId, warehouseId)
+
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra == 0 {
+		return fmt.Errorf("unable to match new order for delete")
+	}
+
+	return nil
+}
+
+func (db *DB) GetCustomer(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Customer, error) {
+
+	query := "SELECT C_ID, C_D_ID, C_W_ID, C_FIRST, C_MIDDLE, C_LAST, C_STREET_1, C_STREET_2, C_CITY, C_STATE, C_ZIP, " +
+		"C_PHONE, C_SINCE, C_CREDIT, C_CREDIT_LIM, C_DISCOUNT, C_BALANCE, C_YTD_PAYMENT, C_PAYMENT_CNT, C_DELIVERY_CNT, C_DATA " +
+		"FROM CUSTOMER WHERE C_W_ID = ? AND C_D_ID = ? AND C_ID = ?"
+
+	var customer models.Customer
+
+	r := db.queryRow(query, warehouseId, districtId, customerId)
+
+	err := r.Scan(
+		&customer.C_ID,
+		&customer.C_D_ID,
+		&customer.C_W_ID,
+		&customer.C_FIRST,
+		&customer.C_MIDDLE,
+		&customer.C_LAST,
+		&customer.C_STREET_1,
+		&customer.C_STREET_2,
+		&customer.C_CITY,
+		&customer.C_STATE,
+		&customer.C_ZIP,
+		&customer.C_PHONE,
+		&customer.C_SINCE,
+		&customer.C_CREDIT,
+		&customer.C_CREDIT_LIM,
+		&customer.C_DISCOUNT,
+		&customer.C_BALANCE,
+		&customer.C_YTD_PAYMENT,
+		&customer.C_PAYMENT_CNT,
+		&customer.C_DELIVERY_CNT,
+		&customer.C_DATA,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &customer, nil
+}
+
+func (db *DB) UpdateOrders(ctx context.Context, orderId int, warehouseId int, districtId int, oCarrierId int, deliveryDate time.Time) error {
+
+	query := "UPDATE ORDERS SET O_CARRIER_ID = ? WHERE O_ID = ? AND O_D_ID = ? AND O_W_ID = ?"
+	r, err := db.exec(query, oCarrierId, orderId, districtId, warehouseId)
+	if err != nil {
+		return err
+	}
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra == 0 {
+		return fmt.Errorf("unable to match order")
+	}
+
+	query = "UPDATE ORDER_LINE SET OL_DELIVERY_D = ? WHERE OL_O_ID = ? AND OL_D_ID = ? AND OL_W_ID = ?"
+	r, err = db.exec(query, deliveryDate, orderId, districtId, warehouseId)
+	if err != nil {
+		return err
+	}
+	ra, err = r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db *DB) SumOLAmount(ctx context.Context, orderId int, warehouseId int, districtId int) (float64, error) {
+
+	query := "SELECT COALESCE(SUM(OL_AMOUNT), 0) FROM ORDER_LINE WHERE OL_O_ID = ? AND OL_D_ID = ? AND OL_W_ID = ?"
+	row := db.queryRow(query, orderId, districtId, warehouseId)
+	var sum float64
+	err := row.Scan(&sum)
+	if err != nil {
+		return 0, err
+	}
+
+	return sum, nil
+}
+
+func (db *DB) UpdateCustomer(ctx context.Context, customerId int, warehouseId int, districtId int, sumOlTotal float64) error {
+	query := "UPDATE CUSTOMER SET C_BALANCE = C_BALANCE + ? WHERE C_ID = ? AND C_D_ID = ? AND C_W_ID = ?"
+
+	res, err := db.exec(query, sumOlTotal, customerId, districtId, warehouseId)
+	if err != nil {
+		return err
+	}
+
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra == 0 {
+		return fmt.Errorf("unable to match customer")
+	}
+
+	return nil
+}
+
+func (db *DB) GetNextOrderId(ctx context.Context, warehouseId int, districtId int) (int, error) {
+	query := "SELECT D_NEXT_O_ID FROM DISTRICT WHERE D_ID = ? AND D_W_ID = ?"
+
+	row := db.queryRow(query, districtId, warehouseId)
+	var dn int
+	err := row.Scan(&dn)
+	if err != nil {
+		return 0, err
+	}
+
+	return dn, nil
+}
+
+func (db *DB) GetStockCount(ctx context.Context, orderIdLt int, orderIdGt int, threshold int, warehouseId int, districtId int) (int64, error) {
+	query := "SELECT COUNT(DISTINCT(OL_I_ID)) FROM " +
+		"ORDER_LINE, STOCK " +
+		"WHERE " +
+		"OL_W_ID = ? AND OL_D_ID = ? " +
+		"AND OL_O_ID < ? AND OL_O_ID >= ? " +
+		"AND S_W_ID = ? AND S_I_ID = OL_I_ID AND S_QUANTITY < ?"
+
+	row := db.queryRow(query, warehouseId, districtId, orderIdLt, orderIdGt, warehouseId, threshold)
+	var count int64
+	err := row.Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (db *DB) GetCustomerById(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Customer, error) {
+	var c models.Customer
+
+	query := "SELECT C_ID, C_FIRST, C_MIDDLE, C_LAST, C_BALANCE FROM CUSTOMER WHERE C_ID = ? AND C_W_ID = ? and C_D_ID = ?"
+
+	row := db.queryRow(query, customerId, warehouseId, districtId)
+	err := row.Scan(&c.C_ID, &c.C_FIRST, &c.C_MIDDLE, &c.C_LAST, &c.C_BALANCE)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (db *DB) GetCustomerByName(ctx context.Context, name string, warehouseId int, districtId int) (*models.Customer, error) {
+
+	query := "SELECT C_ID, C_FIRST, C_MIDDLE, C_LAST, C_BALANCE FROM CUSTOMER WHERE C_W_ID = ? AND C_D_ID = ? AND C_LAST = ?"
+
+	rows, err := db.query(query, warehouseId, districtId, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var customer models.Customer
+	var customers []models.Customer
+	for rows.Next() {
+		err = rows.Scan(
+			&customer.C_ID,
+			&customer.C_FIRST,
+			&customer.C_MIDDLE,
+			&customer.C_LAST,
+			&customer.C_BALANCE,
+		)
+		customers = append(customers, customer)
+	}
+
+	if len(customers) < 1 {
+		return nil, fmt.Errorf("no customers found with given name: %s", name)
+	}
+
+	return &customers[(len(customers)-1)/2], nil
+}
+
+func (db *DB) GetLastOrder(ctx context.Context, customerId int, warehouseId int, districtId int) (*models.Order, error) {
+
+	query := "SELECT O_ID, O_CARRIER_ID, O_ENTRY_D FROM ORDERS WHERE O_W_ID = ? AND O_D_ID = ? AND O_C_ID = ? ORDER BY O_ID DESC LIMIT 1"
+
+	row := db.queryRow(query, warehouseId, districtId, customerId)
+
+	var m models.Order
+
+	err := row.Scan(&m.O_ID, &m.O_CARRIER_ID, &m.O_ENTRY_D)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (db *DB) GetOrderLines(ctx context.Context, orderId int, warehouseId int, districtId int) (*[]models.OrderLine, error) {
+
+	query := "SELECT OL_O_ID, OL_D_ID, OL_W_ID, OL_NUMBER, OL_I_ID, OL_SUPPLY_W_ID, OL_DELIVERY_D, OL_QUANTITY, OL_AMOUNT, OL_DIST_INFO FROM ORDER_LINE " +
+		"WHERE OL_O_ID = ? AND OL_W_ID = ? AND OL_D_ID = ?"
+
+	rows, err := db.query(query, orderId, warehouseId, districtId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ol []models.OrderLine
+
+	for rows.Next() {
+		var o models.OrderLine
+		// NULL until the order is delivered
+		var deliveryD sql.NullTime
+		err = rows.Scan(
+			&o.OL_O_ID,
+			&o.OL_D_ID,
+			&o.OL_W_ID,
+			&o.OL_NUMBER,
+			&o.OL_I_ID,
+			&o.OL_SUPPLY_W_ID,
+			&deliveryD,
+			&o.OL_QUANTITY,
+			&o.OL_AMOUNT,
+			&o.OL_DIST_INFO,
+		)
+		if err != nil {
+			return nil, err
+		}
+		o.OL_DELIVERY_D = deliveryD.Time
+
+		ol = append(ol, o)
+	}
+
+	return &ol, nil
+
+}
+
+func (db *DB) GetWarehouse(ctx context.Context, warehouseId int) (*models.Warehouse, error) {
+
+	query := "SELECT W_ID, W_NAME, W_STREET_1, W_STREET_2, W_CITY, W_STATE, W_ZIP, W_TAX, W_YTD FROM WAREHOUSE WHERE W_ID = ?"
+
+	row := db.queryRow(query, warehouseId)
+
+	var w models.Warehouse
+
+	err := row.Scan(&w.W_ID, &w.W_NAME, &w.W_STREET_1, &w.W_STREET_2, &w.W_CITY, &w.W_STATE, &w.W_ZIP, &w.W_TAX, &w.W_YTD)
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+func (db *DB) UpdateWarehouseBalance(ctx context.Context, warehouseId int, amount float64) error {
+	query := "UPDATE WAREHOUSE SET W_YTD = W_YTD + ? WHERE W_ID = ?"
+
+	r, err := db.exec(query, amount, warehouseId)
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra == 0 {
+		return fmt.Errorf("unable to match warehouse")
+	}
+
+	return nil
+}
+
+func (db *DB) GetDistrict(ctx context.Context, warehouseId int, districtId int) (*models.District, error) {
+	query := db.forUpdate("SELECT D_ID, D_W_ID, D_NAME, D_STREET_1, D_STREET_2, D_CITY, D_STATE, D_ZIP, D_TAX, D_YTD, D_NEXT_O_ID FROM DISTRICT WHERE D_W_ID = ? and D_ID = ?")
+
+	r := db.queryRow(query, warehouseId, districtId)
+	var d models.District
+
+	err := r.Scan(
+		&d.D_ID,
+		&d.D_W_ID,
+		&d.D_NAME,
+		&d.D_STREET_1,
+		&d.D_STREET_2,
+		&d.D_CITY,
+		&d.D_STATE,
+		&d.D_ZIP,
+		&d.D_TAX,
+		&d.D_YTD,
+		&d.D_NEXT_O_ID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+func (db *DB) UpdateDistrictBalance(ctx context.Context, warehouseId int, districtId int, amount float64) error {
+
+	query := "UPDATE DISTRICT SET D_YTD = D_YTD + ? WHERE D_W_ID = ? AND D_ID = ?"
+
+	r, err := db.exec(query, amount, warehouseId, districtId)
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra == 0 {
+		return fmt.Errorf("Unable to match district")
+	}
+
+	return nil
+}
+
+func (db *DB) InsertHistory(ctx context.Context, warehouseId int, districtId int, date time.Time, amount float64, data string) error {
+	query := "INSERT INTO HISTORY (H_C_ID, H_D_ID, H_W_ID, H_C_W_ID, H_C_D_ID, H_DATE, H_AMOUNT, H_DATA) VALUES (?,?,?,?,?,?,?,?)"
+
+	_, err := db.exec(query, 1, districtId, warehouseId, warehouseId, districtId, date, amount, data)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db *DB) GetCustomerIdOrder(ctx context.Context, orderId int, warehouseId int, districtId int) (int, error) {
+
+	query := "SELECT O_C_ID FROM ORDERS WHERE O_ID = ? AND O_D_ID = ? AND O_W_ID = ?"
+
+	r := db.queryRow(query, orderId, districtId, warehouseId)
+
+	var cId int
+
+	err := r.Scan(&cId)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return cId, nil
+}
+
+func (db *DB) UpdateCredit(ctx context.Context, customerId int, warehouseId int, districtId int, balance float64, data string) error {
+
+	var err error
+	var res sql.Result
+
+	if len(data) > 0 {
+		res, err = db.exec("UPDATE CUSTOMER SET "+
+			"C_BALANCE = C_BALANCE + ?, C_YTD_PAYMENT = C_YTD_PAYMENT + ?, C_PAYMENT_CNT = C_PAYMENT_CNT + ?, C_DATA = ? "+
+			"WHERE C_ID = ? AND C_W_ID = ? AND C_D_ID = ?",
+			-1*balance,
+			balance,
+			1,
+			data,
+			customerId,
+			warehouseId,
+			districtId,
+		)
+	} else {
+		res, err = db.exec("UPDATE CUSTOMER SET "+
+			"C_BALANCE = C_BALANCE + ?, C_YTD_PAYMENT = C_YTD_PAYMENT + ?, C_PAYMENT_CNT = C_PAYMENT_CNT + ? "+
+			"WHERE C_ID = ? AND C_W_ID = ? AND C_D_ID = ?",
+			-1*balance,
+			balance,
+			1,
+			customerId,
+			warehouseId,
+			districtId,
+		)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra == 0 {
+		return fmt.Errorf("no customers matched")
+	}
+
+	return nil
+}
+
+func (db *DB) CreateOrder(ctx context.Context,
+	orderId int,
+	customerId int,
+	warehouseId int,
+	districtId int,
+	oCarrierId int,
+	oOlCnt int,
+	allLocal int,
+	orderEntryDate time.Time,
+	orderLine []models.OrderLine,
+) error {
+
+	query := "INSERT INTO ORDERS (O_ID, O_C_ID, O_D_ID, O_W_ID, O_ENTRY_D, O_CARRIER_ID, O_OL_CNT, O_ALL_LOCAL) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+
+	_, err := db.exec(query, orderId, customerId, districtId, warehouseId, orderEntryDate, oCarrierId, oOlCnt, allLocal)
+
+	if err != nil {
+		return err
+	}
+
+	query = "INSERT INTO NEW_ORDER (NO_O_ID, NO_D_ID, NO_W_ID) VALUES (?, ?, ?)"
+	_, err = db.exec(query, orderId, districtId, warehouseId)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range orderLine {
+		query = "INSERT INTO ORDER_LINE (OL_O_ID, OL_D_ID, OL_W_ID, OL_NUMBER, OL_I_ID, OL_SUPPLY_W_ID, OL_QUANTITY, OL_AMOUNT, OL_DIST_INFO) " +
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+		_, err = db.exec(query, o.OL_O_ID, districtId, warehouseId, o.OL_NUMBER, o.OL_I_ID, o.OL_SUPPLY_W_ID, o.OL_QUANTITY, o.OL_AMOUNT, o.OL_DIST_INFO)
+		if err != nil {
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) GetItems(ctx context.Context, itemIds []int) (*[]models.Item, error) {
+	var itemIds_ []string
+
+	for _, item := range itemIds {
+		itemIds_ = append(itemIds_, strconv.Itoa(item))
+	}
+
+	query := fmt.Sprintf("SELECT I_PRICE, I_NAME, I_DATA FROM ITEM WHERE I_ID IN (%s)", strings.Join(itemIds_, ","))
+
+	rows, err := db.query(query)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []models.Item
+
+	for rows.Next() {
+		var item models.Item
+
+		err = rows.Scan(&item.I_PRICE, &item.I_NAME, &item.I_DATA)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return &items, nil
+}
+
+func (db *DB) UpdateStock(ctx context.Context, stockId int, warehouseId int, quantity int, ytd int, ordercnt int, remotecnt int) error {
+
+	query := "UPDATE STOCK SET S_QUANTITY = ?, S_YTD = ?, S_ORDER_CNT = ?, S_REMOTE_CNT = ? WHERE S_I_ID = ? AND S_W_ID = ?"
+
+	r, err := db.exec(query, quantity, ytd, ordercnt, remotecnt, stockId, warehouseId)
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra == 0 {
+		return fmt.Errorf("unable to match stock")
+	}
+
+	return nil
+}
+
+func (db *DB) GetStockInfo(ctx context.Context, districtId int, iIds []int, iWids []int, allLocal int) (*[]models.Stock, error) {
+
+	var buf string
+
+	if allLocal == 1 {
+		var iIds_ []string
+
+		for _, item := range iIds {
+			iIds_ = append(iIds_, strconv.Itoa(item))
+		}
+
+		buf = fmt.Sprintf(" S_W_ID = %d AND S_I_ID IN (%s)", iWids[0], strings.Join(iIds_, ","))
+
+	} else {
+		var p []string
+
+		for i, item := range iIds {
+			p = append(p, fmt.Sprintf("(S_W_ID = %d AND S_I_ID = %d)", iWids[i], item))
+		}
+
+		buf = strings.Join(p, " OR ")
+	}
+
+	query := fmt.Sprintf("SELECT S_I_ID, S_W_ID, S_QUANTITY, S_DATA, S_YTD, S_ORDER_CNT, S_REMOTE_CNT, S_DIST_%02d FROM STOCK "+
+		"WHERE %s", districtId, buf)
+
+	rows, err := db.query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stocks []models.Stock
+	for rows.Next() {
+		var stock models.Stock
+
+		distcol := distCol(&stock, districtId)
+
+		err = rows.Scan(&stock.S_I_ID, &stock.S_W_ID, &stock.S_QUANTITY, &stock.S_DATA, &stock.S_YTD, &stock.S_ORDER_CNT, &stock.S_REMOTE_CNT, distcol)
+		if err != nil {
+			return nil, err
+		}
+		stocks = append(stocks, stock)
+
+	}
+	return &stocks, nil
+}
+
+// distCol returns the S_DIST_xx field of the district
+func distCol(stock *models.Stock, districtId int) *string {
+	switch districtId {
+	case 1:
+		return &stock.S_DIST_01
+	case 2:
+		return &stock.S_DIST_02
+	case 3:
+		return &stock.S_DIST_03
+	case 4:
+		return &stock.S_DIST_04
+	case 5:
+		return &stock.S_DIST_05
+	case 6:
+		return &stock.S_DIST_06
+	case 7:
+		return &stock.S_DIST_07
+	case 8:
+		return &stock.S_DIST_08
+	case 9:
+		return &stock.S_DIST_09
+	case 10:
+		return &stock.S_DIST_10
+	default:
+		panic("incorrect districtId")
+	}
+}
+
+// IncrementDistrictOrderIdAndGet increments D_NEXT_O_ID and returns the district in one UPDATE ... RETURNING
+func (db *DB) IncrementDistrictOrderIdAndGet(ctx context.Context, warehouseId int, districtId int) (*models.District, error) {
+	if !db.dialect.Returning {
+		return nil, fmt.Errorf("findAndModify is not supported by %s", db.dialect.Name)
+	}
+
+	query := "UPDATE DISTRICT SET D_NEXT_O_ID = D_NEXT_O_ID + 1 WHERE D_W_ID = ? AND D_ID = ? " +
+		"RETURNING D_ID, D_W_ID, D_NAME, D_STREET_1, D_STREET_2, D_CITY, D_STATE, D_ZIP, D_TAX, D_YTD, D_NEXT_O_ID"
+
+	r := db.queryRow(query, warehouseId, districtId)
+	var d models.District
+
+	err := r.Scan(
+		&d.D_ID,
+		&d.D_W_ID,
+		&d.D_NAME,
+		&d.D_STREET_1,
+		&d.D_STREET_2,
+		&d.D_CITY,
+		&d.D_STATE,
+		&d.D_ZIP,
+		&d.D_TAX,
+		&d.D_YTD,
+		&d.D_NEXT_O_ID,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unable to match district")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// UpdateStockAndGet applies the New-Order stock update and returns the new row in one UPDATE ... RETURNING
+func (db *DB) UpdateStockAndGet(ctx context.Context, districtId int, stockId int, warehouseId int, quantity int, remote bool) (*models.Stock, error) {
+	if !db.dialect.Returning {
+		return nil, fmt.Errorf("findAndModify is not supported by %s", db.dialect.Name)
+	}
+
+	remoteCnt := 0
+	if remote {
+		remoteCnt = 1
+	}
+
+	query := fmt.Sprintf("UPDATE STOCK SET "+
+		"S_QUANTITY = CASE WHEN S_QUANTITY >= ? THEN S_QUANTITY - ? ELSE S_QUANTITY + ? END, "+
+		"S_YTD = S_YTD + ?, S_ORDER_CNT = S_ORDER_CNT + 1, S_REMOTE_CNT = S_REMOTE_CNT + ? "+
+		"WHERE S_I_ID = ? AND S_W_ID = ? "+
+		"RETURNING S_I_ID, S_W_ID, S_QUANTITY, S_DATA, S_YTD, S_ORDER_CNT, S_REMOTE_CNT, S_DIST_%02d", districtId)
+
+	r := db.queryRow(query, quantity+10, quantity, 91-quantity, quantity, remoteCnt, stockId, warehouseId)
+	var stock models.Stock
+
+	err := r.Scan(&stock.S_I_ID, &stock.S_W_ID, &stock.S_QUANTITY, &stock.S_DATA, &stock.S_YTD, &stock.S_ORDER_CNT, &stock.S_REMOTE_CNT, distCol(&stock, districtId))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unable to match stock")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &stock, nil
+}
@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Percona-Lab/go-tpcc/databases"
+	"github.com/Percona-Lab/go-tpcc/databases/sqldb"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect runs the SQL backend against SQLite. SQLite maps the MySQL column types to its own type affinities,
+// it has no row locks and cannot add constraints to existing tables, so no foreign keys are created.
+var Dialect = sqldb.Dialect{
+	Name:      "SQLite",
+	Driver:    "sqlite3",
+	Returning: true,
+	// let the query planner pick the indexes on the loaded data
+	Analyze:      "ANALYZE",
+	Retryable:    retryable,
+	VersionQuery: "SELECT sqlite_version()",
+}
+
+// another connection held the database lock longer than the busy timeout
+func retryable(err error) bool {
+	var e sqlite3.Error
+	return errors.As(err, &e) && (e.Code == sqlite3.ErrBusy || e.Code == sqlite3.ErrLocked)
+}
+
+func init() {
+	databases.Register("sqlite", func(o databases.Options) (databases.Database, error) {
+		wal, err := o.BoolParam("sqlite-wal", true)
+		if err != nil {
+			return nil, err
+		}
+
+		synchronous := o.Param("sqlite-synchronous", "normal")
+		switch synchronous {
+		case "off", "normal", "full", "extra":
+		default:
+			return nil, fmt.Errorf("driver parameter sqlite-synchronous: %q is not off, normal, full or extra", synchronous)
+		}
+
+		return NewSQLite(o.URI, o.Transactions, wal, synchronous)
+	}, databases.Capabilities{
+		Description:   "embedded SQLite, --uri is the database file (needs cgo)",
+		Transactions:  true,
+		FindAndModify: true,
+	})
+}
+
+// NewSQLite opens the database file uri. wal switches the journal to write-ahead logging,
+// synchronous is the PRAGMA synchronous level (off|normal|full|extra).
+// Transactions take the write lock on BEGIN, so concurrent workers wait for each other instead of deadlocking.
+func NewSQLite(uri string, transactions bool, wal bool, synchronous string) (*sqldb.DB, error) {
+	params := []string{
+		"_busy_timeout=5000",
+		"_txlock=immediate",
+		"_synchronous=" + synchronous,
+	}
+	if wal {
+		params = append(params, "_journal_mode=WAL")
+	}
+
+	var uri_ string
+	if strings.Contains(uri, "?") {
+		uri_ = fmt.Sprintf("%s&%s", uri, strings.Join(params, "&"))
+	} else {
+		uri_ = fmt.Sprintf("%s?%s", uri, strings.Join(params, "&"))
+	}
+
+	return sqldb.Open(&Dialect, uri_, transactions)
+}
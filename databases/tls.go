@@ -0,0 +1,48 @@
+package databases
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSConfig builds the client TLS configuration from PEM files. ca verifies the server instead of the system pool,
+// cert and key are the client certificate. It returns nil if none of the settings is given, the drivers then
+// keep whatever the URI asks for.
+func TLSConfig(ca string, cert string, key string, skipVerify bool) (*tls.Config, error) {
+	if ca == "" && cert == "" && key == "" && !skipVerify {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		InsecureSkipVerify: skipVerify,
+	}
+
+	if ca != "" {
+		pem, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", ca)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if cert != "" || key != "" {
+		if cert == "" || key == "" {
+			return nil, fmt.Errorf("client certificate and key have to be given together")
+		}
+
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+
+	return cfg, nil
+}
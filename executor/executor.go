@@ -2,8 +2,8 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
 	"time"
 
 	"github.com/Percona-Lab/go-tpcc/databases"
@@ -19,23 +19,31 @@ func (v Values) Get(key string) string {
 }
 
 type Executor struct {
-	batchSize   int
-	data        map[string][]interface{}
-	db          databases.Database
-	retries     int
-	transaction bool
+	batchSize     int
+	data          map[string][]interface{}
+	db            databases.Database
+	retries       int
+	transaction   bool
+	trxCallback   bool
+	findAndModify bool
+	retried       int
 }
 
 const DefaultRetries = 10
 
+// ErrRollback is returned by New-Order for an unused item id, TPC-C rolls back 1% of the New-Order transactions on purpose
+var ErrRollback = errors.New("TPCC defines 1% of neworder gives a wrong itemid, causing rollback. This happens on purpose")
+
 func NewExecutor(db databases.Database, batchSize int) (*Executor, error) {
 
 	return &Executor{
-		batchSize:   512,
-		data:        make(map[string][]interface{}),
-		db:          db,
-		retries:     DefaultRetries,
-		transaction: false,
+		batchSize:     512,
+		data:          make(map[string][]interface{}),
+		db:            db,
+		retries:       DefaultRetries,
+		transaction:   false,
+		trxCallback:   false,
+		findAndModify: false,
 	}, nil
 }
 
@@ -47,6 +55,36 @@ func (e *Executor) ChangeRetries(r int) {
 	e.retries = r
 }
 
+func (e *Executor) ChangeTransactions(t bool) {
+	e.transaction = t
+}
+
+// ChangeTrxCallback makes transactions run through the database's WithTransaction
+// instead of StartTrx/CommitTrx, leaving commit retries to the driver
+func (e *Executor) ChangeTrxCallback(c bool) {
+	e.trxCallback = c
+}
+
+// ChangeFindAndModify switches New-Order to the atomic read-modify-write calls of the database
+func (e *Executor) ChangeFindAndModify(f bool) {
+	e.findAndModify = f
+}
+
+// Retried returns how many times DoTrxRetries ran a transaction again since the executor was created.
+// Retries done by the driver in WithTransaction are not counted.
+func (e *Executor) Retried() int {
+	return e.retried
+}
+
+// ServerVersion returns the version of the database server, "" if the driver can't tell
+func (e *Executor) ServerVersion(ctx context.Context) (string, error) {
+	if v, ok := e.db.(databases.Versioner); ok {
+		return v.ServerVersion(ctx)
+	}
+
+	return "", nil
+}
+
 // @TODO@
 // Error handling
 
@@ -85,14 +123,27 @@ func (e *Executor) Save(ctx context.Context, collectionName string, d interface{
 func (e *Executor) DoTrxRetries(ctx context.Context, dId int, fn func(ctx context.Context) (context.Context, error)) error {
 	var err error
 
+	if e.transaction && e.trxCallback {
+		return e.db.WithTransaction(ctx, func(ctx context.Context) error {
+			_, err := fn(ctx)
+			return err
+		})
+	}
+
 	retries := e.retries
 
+	// without a transaction the writes of a failed attempt stay applied, so it is not run again
 	if !e.transaction {
 		retries = 1
 	}
 
+	// a failed commit or a retryable error (e.g. a version conflict) runs fn again,
+	// any other error returned by fn aborts the transaction
 	for i := 0; i < retries; i++ {
-		err = nil
+		if i > 0 {
+			e.retried++
+		}
+
 		if e.transaction {
 			err = e.db.StartTrx()
 			if err != nil {
@@ -100,7 +151,8 @@ func (e *Executor) DoTrxRetries(ctx context.Context, dId int, fn func(ctx contex
 			}
 		}
 
-		ctx2, err := fn(ctx)
+		var ctx2 context.Context
+		ctx2, err = fn(ctx)
 
 		if err != nil {
 			if e.transaction {
@@ -109,20 +161,31 @@ func (e *Executor) DoTrxRetries(ctx context.Context, dId int, fn func(ctx contex
 					return e
 				}
 			}
-			break
+			if retryable(err) {
+				continue
+			}
+			return err
 		}
 
-		if e.transaction {
-			err := e.db.CommitTrx(ctx2)
-			if err != nil {
-				return err
-			}
+		if !e.transaction {
+			return nil
+		}
+
+		err = e.db.CommitTrx(ctx2)
+		if err == nil {
+			return nil
 		}
 	}
 
 	return err
 }
 
+// retryable reports whether the database marked err as temporary
+func retryable(err error) bool {
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
+
 func (e *Executor) DoStockLevelTrx(ctx context.Context, warehouseId int, districtId int, threshold int) error {
 	// Do Stock Level never requires a transactions
 
@@ -143,13 +206,7 @@ func (e *Executor) DoStockLevelTrx(ctx context.Context, warehouseId int, distric
 func (e *Executor) DoDeliveryTrx(ctx context.Context, wId int, oCarrierId int, olDeliveryD time.Time, dId int) error {
 	for i := 1; i <= dId; i++ {
 		err := e.DoTrxRetries(ctx, i, func(ctx context.Context) (context.Context, error) {
-			ctx2, err := e.DoDelivery(ctx, wId, oCarrierId, olDeliveryD, i)
-			if err != nil {
-				panic(err)
-			}
-
-			return ctx2, nil
-
+			return e.DoDelivery(ctx, wId, oCarrierId, olDeliveryD, i)
 		})
 
 		if err != nil {
@@ -164,7 +221,7 @@ func (e *Executor) DoDeliveryTrx(ctx context.Context, wId int, oCarrierId int, o
 func (e *Executor) DoDelivery(ctx context.Context, wId int, oCarrierId int, olDeliveryD time.Time, dId int) (context.Context, error) {
 
 	// save new order for Rollback
-	co, ID, err := e.db.CheckNewOrder(ctx, wId, dId)
+	co, _, err := e.db.CheckNewOrder(ctx, wId, dId)
 	// not exist only elasticsearch
 	if err != nil {
 		return nil, err
@@ -175,13 +232,8 @@ func (e *Executor) DoDelivery(ctx context.Context, wId int, oCarrierId int, olDe
 	if co != nil {
 		ctrx := context.WithValue(ctx, "co"+string(rune(co.NO_O_ID)), co)
 
-		_ID, err := strconv.Atoi(*ID)
-		if err != nil {
-			return ctrx, err
-		}
-
-		// find by logical id (delete)
-		no, err := e.db.GetNewOrder(ctx, _ID, dId)
+		// find the oldest one again (delete)
+		no, err := e.db.GetNewOrder(ctx, wId, dId)
 		if err != nil {
 			return ctrx, err
 		}
@@ -363,7 +415,10 @@ func (e *Executor) DoPayment(
 		var buf string
 
 		buf = fmt.Sprintf("%v %v %v %v %v %v|%v", cId, cDId, cWId, districtId, warehouseId, amount, customer.C_DATA)
-		err = e.db.UpdateCredit(ctx, cId, warehouseId, districtId, amount, buf[:cdatalen])
+		if len(buf) > cdatalen {
+			buf = buf[:cdatalen]
+		}
+		err = e.db.UpdateCredit(ctx, cId, warehouseId, districtId, amount, buf)
 
 		if err != nil {
 			return err
@@ -382,8 +437,6 @@ func (e *Executor) DoPayment(
 	err = e.db.InsertHistory(ctx, warehouseId, districtId, time.Now(), amount, hData)
 
 	if err != nil {
-		panic(err)
-		panic("here")
 		return err
 	}
 
@@ -393,13 +446,7 @@ func (e *Executor) DoPayment(
 func (e *Executor) DoNewOrderTrx(ctx context.Context, wId, dId, cId int, oEntryD time.Time, iIds []int, iWids []int, iQtys []int) error {
 	for i := 1; i <= dId; i++ {
 		err := e.DoTrxRetries(ctx, i, func(ctx context.Context) (context.Context, error) {
-			ctx2, err := e.DoNewOrder(ctx, wId, dId, cId, oEntryD, iIds, iWids, iQtys)
-			if err != nil {
-				panic(err)
-			}
-
-			return ctx2, nil
-
+			return e.DoNewOrder(ctx, wId, dId, cId, oEntryD, iIds, iWids, iQtys)
 		})
 
 		if err != nil {
@@ -416,14 +463,24 @@ func (e *Executor) DoNewOrder(ctx context.Context, wId, dId, cId int, oEntryD ti
 		return ctx, err
 	}
 
-	district, err := e.db.GetDistrict(ctx, wId, dId)
-	if err != nil {
-		return ctx, err
-	}
+	var oId int
+	if e.findAndModify {
+		district, err := e.db.IncrementDistrictOrderIdAndGet(ctx, wId, dId)
+		if err != nil {
+			return ctx, err
+		}
+		oId = district.D_NEXT_O_ID - 1
+	} else {
+		district, err := e.db.GetDistrict(ctx, wId, dId)
+		if err != nil {
+			return ctx, err
+		}
 
-	err = e.db.IncrementDistrictOrderId(ctx, wId, dId)
-	if err != nil {
-		return ctx, err
+		err = e.db.IncrementDistrictOrderId(ctx, wId, dId)
+		if err != nil {
+			return ctx, err
+		}
+		oId = district.D_NEXT_O_ID
 	}
 
 	_, err = e.db.GetCustomer(ctx, cId, wId, dId)
@@ -445,62 +502,82 @@ func (e *Executor) DoNewOrder(ctx context.Context, wId, dId, cId int, oEntryD ti
 	}
 
 	if len(*items) != len(iIds) {
-		return ctx, fmt.Errorf("TPCC defines 1%% of neworder gives a wrong itemid, causing rollback. This happens on purpose")
-	}
-
-	stocks, err := e.db.GetStockInfo(ctx, dId, iIds, iWids, allLocal)
-	if err != nil {
-		return ctx, err
-	}
-
-	if len(*stocks) != len(iIds) {
-		return ctx, fmt.Errorf("len(stocks) != len(i_ids)")
+		return ctx, ErrRollback
 	}
 
 	var orderLines []models.OrderLine
 
-	for i := 0; i < len(iIds); i++ {
-		sQuantity := (*stocks)[i].S_QUANTITY
+	if e.findAndModify {
+		for i := 0; i < len(iIds); i++ {
+			stock, err := e.db.UpdateStockAndGet(ctx, dId, iIds[i], iWids[i], iQtys[i], iWids[i] != wId)
+			if err != nil {
+				return ctx, err
+			}
 
-		if sQuantity >= 10+iQtys[i] {
-			sQuantity -= iQtys[0]
-		} else {
-			sQuantity += 91 - iQtys[0]
+			orderLines = append(orderLines, models.OrderLine{
+				OL_O_ID:        oId,
+				OL_NUMBER:      i + 1,
+				OL_I_ID:        iIds[i],
+				OL_SUPPLY_W_ID: iWids[i],
+				OL_DELIVERY_D:  oEntryD,
+				OL_QUANTITY:    iQtys[i],
+				OL_AMOUNT:      (*items)[i].I_PRICE * float64(iQtys[i]),
+				OL_DIST_INFO:   distCol(dId, stock),
+			})
+		}
+	} else {
+		stocks, err := e.db.GetStockInfo(ctx, dId, iIds, iWids, allLocal)
+		if err != nil {
+			return ctx, err
 		}
 
-		S_REMOTE_CNT := (*stocks)[i].S_REMOTE_CNT
-
-		if iWids[i] != wId {
-			S_REMOTE_CNT += 1
+		if len(*stocks) != len(iIds) {
+			return ctx, fmt.Errorf("len(stocks) != len(i_ids)")
 		}
 
-		err = e.db.UpdateStock(
-			ctx,
-			(*stocks)[i].S_I_ID,
-			iWids[1],
-			sQuantity,
-			(*stocks)[i].S_YTD+iQtys[i],
-			(*stocks)[i].S_ORDER_CNT+1,
-			S_REMOTE_CNT,
-		)
+		for i := 0; i < len(iIds); i++ {
+			sQuantity := (*stocks)[i].S_QUANTITY
 
-		if err != nil {
-			return ctx, err
-		}
+			if sQuantity >= 10+iQtys[i] {
+				sQuantity -= iQtys[i]
+			} else {
+				sQuantity += 91 - iQtys[i]
+			}
 
-		orderLines = append(orderLines, models.OrderLine{
-			OL_O_ID:        district.D_NEXT_O_ID,
-			OL_NUMBER:      i + 1,
-			OL_I_ID:        iIds[i],
-			OL_SUPPLY_W_ID: iWids[i],
-			OL_DELIVERY_D:  oEntryD,
-			OL_QUANTITY:    iQtys[i],
-			OL_AMOUNT:      (*items)[i].I_PRICE * float64(iQtys[i]),
-			OL_DIST_INFO:   distCol(dId, &(*stocks)[i]),
-		})
+			S_REMOTE_CNT := (*stocks)[i].S_REMOTE_CNT
+
+			if iWids[i] != wId {
+				S_REMOTE_CNT += 1
+			}
+
+			err = e.db.UpdateStock(
+				ctx,
+				(*stocks)[i].S_I_ID,
+				iWids[i],
+				sQuantity,
+				(*stocks)[i].S_YTD+iQtys[i],
+				(*stocks)[i].S_ORDER_CNT+1,
+				S_REMOTE_CNT,
+			)
+
+			if err != nil {
+				return ctx, err
+			}
+
+			orderLines = append(orderLines, models.OrderLine{
+				OL_O_ID:        oId,
+				OL_NUMBER:      i + 1,
+				OL_I_ID:        iIds[i],
+				OL_SUPPLY_W_ID: iWids[i],
+				OL_DELIVERY_D:  oEntryD,
+				OL_QUANTITY:    iQtys[i],
+				OL_AMOUNT:      (*items)[i].I_PRICE * float64(iQtys[i]),
+				OL_DIST_INFO:   distCol(dId, &(*stocks)[i]),
+			})
+		}
 	}
 
-	err = e.db.CreateOrder(ctx, district.D_NEXT_O_ID, cId, wId, dId, 0, len(iIds), allLocal, oEntryD, orderLines)
+	err = e.db.CreateOrder(ctx, oId, cId, wId, dId, 0, len(iIds), allLocal, oEntryD, orderLines)
 	if err != nil {
 		return ctx, err
 	}
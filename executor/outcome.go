@@ -0,0 +1,118 @@
+package executor
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// Outcome classifies how a transaction ended
+type Outcome int
+
+const (
+	// Committed transactions succeeded at the first attempt
+	Committed Outcome = iota
+	// ExpectedRollback is a New-Order rolled back on purpose, see ErrRollback
+	ExpectedRollback
+	// RetriedCommitted transactions succeeded after retrying retryable errors
+	RetriedCommitted
+	// Aborted transactions still hit a conflict, e.g. a deadlock, after all retries
+	Aborted
+	// Error is any other failure
+	Error
+)
+
+var outcomeNames = map[Outcome]string{
+	Committed:        "committed",
+	ExpectedRollback: "expected_rollback",
+	RetriedCommitted: "retried_committed",
+	Aborted:          "aborted",
+	Error:            "error",
+}
+
+func (o Outcome) String() string {
+	return outcomeNames[o]
+}
+
+// Failed is true unless the transaction committed
+func (o Outcome) Failed() bool {
+	return o != Committed && o != RetriedCommitted
+}
+
+// Outcomes are all outcomes in the order they are reported
+var Outcomes = []Outcome{Committed, RetriedCommitted, ExpectedRollback, Aborted, Error}
+
+// Result is how a transaction run by Run ended
+type Result struct {
+	Outcome Outcome
+	// Retries is how many times the transaction was run again after a retryable error
+	Retries int
+	// Err is the error of a failed transaction and ErrorClass its ErrorClass
+	Err        error
+	ErrorClass string
+}
+
+// Run runs a transaction, fn calls one of the Do...Trx methods, and classifies how it ended
+func (e *Executor) Run(fn func() error) Result {
+	retried := e.retried
+	err := fn()
+
+	r := Result{
+		Retries:    e.retried - retried,
+		Err:        err,
+		ErrorClass: ErrorClass(err),
+	}
+
+	switch {
+	case err == nil && r.Retries == 0:
+		r.Outcome = Committed
+	case err == nil:
+		r.Outcome = RetriedCommitted
+	case errors.Is(err, ErrRollback):
+		r.Outcome = ExpectedRollback
+	case retryable(err):
+		r.Outcome = Aborted
+	default:
+		r.Outcome = Error
+	}
+
+	return r
+}
+
+// error classes returned by ErrorClass
+const (
+	ErrorRollback   = "rollback"
+	ErrorConflict   = "conflict"
+	ErrorConnection = "connection"
+	ErrorTimeout    = "timeout"
+	ErrorCanceled   = "canceled"
+	ErrorOther      = "other"
+)
+
+// ErrorClass groups the error of a transaction for reporting, it returns "" for nil.
+// Conflicts are the errors the driver marks as retryable, e.g. deadlocks, serialization failures and version conflicts.
+func ErrorClass(err error) string {
+	var t interface{ Timeout() bool }
+	var op *net.OpError
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrRollback):
+		return ErrorRollback
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &t) && t.Timeout():
+		return ErrorTimeout
+	case retryable(err):
+		return ErrorConflict
+	case errors.As(err, &op), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE):
+		return ErrorConnection
+	default:
+		return ErrorOther
+	}
+}
@@ -1,14 +1,18 @@
 module github.com/Percona-Lab/go-tpcc
 
 require (
+	github.com/HdrHistogram/hdrhistogram-go v1.1.2
 	github.com/elastic/go-elasticsearch v0.0.0 // indirect
 	github.com/elastic/go-elasticsearch/v7 v7.14.0
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/jackc/pgconn v1.7.0
 	github.com/jackc/pgx/v4 v4.9.0
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/mitchellh/go-homedir v1.1.0
+	github.com/prometheus/client_golang v1.11.0
 	github.com/spf13/cobra v1.0.0
 	github.com/spf13/viper v1.7.1
+	go.etcd.io/bbolt v1.3.6
 	go.mongodb.org/mongo-driver v1.4.2
 )
 
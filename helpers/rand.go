@@ -6,9 +6,17 @@ import (
 	"time"
 )
 
-func randomString(length int, charset string) string {
+func init() {
 	rand.Seed(time.Now().UnixNano())
+}
 
+// Seed seeds the generator of all helpers. The workers share it, so a seed only makes the sequence of values
+// reproducible, not which worker gets which of them.
+func Seed(seed int64) {
+	rand.Seed(seed)
+}
+
+func randomString(length int, charset string) string {
 	b := make([]byte, length)
 	for i := range b {
 		b[i] = charset[rand.Intn(len(charset))]
@@ -24,14 +32,10 @@ func RandNumericString(length int) string {
 	return randomString(length, "01234567890")
 }
 func RandInt(minimum int, maximum int) int {
-	rand.Seed(time.Now().UnixNano())
-
 	return rand.Intn(maximum - minimum + 1) + minimum
 }
 
 func RandIntExcluding(minimum int, maximum int, excluding int) int {
-	rand.Seed(time.Now().UnixNano())
-
 	n := RandInt(minimum, maximum-1)
 	if n >= excluding {
 		n += 1
@@ -77,3 +81,7 @@ func SelectUniqueIds(numUnique int, minimum int, maximum int) []int {
 	return res
 }
 
+// RandExpFloat returns an exponentially distributed random float with mean mean, e.g. the gap between Poisson arrivals.
+func RandExpFloat(mean float64) float64 {
+	return rand.ExpFloat64() * mean
+}
@@ -0,0 +1,315 @@
+// Package report renders the output of a run as a self-contained HTML page
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/executor"
+	"github.com/Percona-Lab/go-tpcc/stats"
+)
+
+// types are the transaction types in the order of the output of run, each with the color of its line
+var types = []struct {
+	name  string
+	color string
+}{
+	{"StockLevel", "#1f77b4"},
+	{"Delivery", "#ff7f0e"},
+	{"OrderStatus", "#2ca02c"},
+	{"Payment", "#d62728"},
+	{"NewOrder", "#9467bd"},
+}
+
+type series struct {
+	name   string
+	color  string
+	values []float64
+}
+
+type summaryRow struct {
+	Name    string
+	Count   int64
+	TPS     float64
+	Latency *stats.Summary
+}
+
+type page struct {
+	Title    string
+	Config   [][2]string
+	Duration float64
+	TpmC     float64
+	Failed   int64
+	Summary  []summaryRow
+	Outcomes []string
+	// OutcomeRows has a row per type with its name and the count of every outcome, nil if the output has none
+	OutcomeRows [][]string
+	Errors      []stats.ErrorCount
+	Percentile  string
+	Charts      []template.HTML
+}
+
+// HTML writes the report of r to w, it has no external dependencies and can be opened offline
+func HTML(w io.Writer, r *stats.Result, title string) error {
+	p := page{
+		Title:      title,
+		Duration:   r.Duration,
+		TpmC:       r.TpmC(),
+		Failed:     r.Failed,
+		Percentile: "percentile",
+	}
+
+	if r.Header != nil {
+		h := r.Header
+		c := h.Config
+		p.Percentile = fmt.Sprintf("p%g", c.Percentile)
+		p.Config = [][2]string{
+			{"run id", h.RunID},
+			{"start", h.Start.Format(time.RFC3339)},
+			{"seed", fmt.Sprint(h.Seed)},
+			{"driver", h.Driver},
+			{"server version", h.ServerVersion},
+			{"db", c.DB},
+			{"warehouses", fmt.Sprint(c.Warehouses)},
+			{"scalefactor", fmt.Sprint(c.ScaleFactor)},
+			{"threads", fmt.Sprint(c.Threads)},
+			{"time", fmt.Sprintf("%ds", c.Time)},
+			{"report interval", fmt.Sprintf("%ds", c.ReportInterval)},
+			{"percentile", fmt.Sprint(c.Percentile)},
+			{"percent-fail", fmt.Sprint(c.PercentFail)},
+			{"trx", fmt.Sprint(c.Transactions)},
+			{"trx-mode", c.TrxMode},
+			{"findandmodify", fmt.Sprint(c.FindAndModify)},
+			{"aggregate", fmt.Sprint(c.Aggregate)},
+		}
+	}
+
+	for _, t := range types {
+		row := summaryRow{
+			Name:  t.name,
+			Count: r.Transactions[t.name],
+			TPS:   r.Throughput(t.name),
+		}
+		if l, ok := r.Latency[t.name]; ok {
+			row.Latency = &l
+		}
+		p.Summary = append(p.Summary, row)
+	}
+
+	if r.Outcomes != nil {
+		for _, o := range executor.Outcomes {
+			p.Outcomes = append(p.Outcomes, o.String())
+		}
+
+		for _, t := range types {
+			row := []string{t.name}
+			for _, o := range p.Outcomes {
+				row = append(row, fmt.Sprint(r.Outcomes[t.name][o]))
+			}
+			p.OutcomeRows = append(p.OutcomeRows, row)
+		}
+	}
+	p.Errors = r.Errors
+
+	var xs []float64
+	total := series{name: "total", color: "#333333"}
+	failures := series{name: "failed", color: "#d62728"}
+	throughput := make([]series, len(types))
+	latency := make([]series, len(types))
+	for n, t := range types {
+		throughput[n] = series{name: t.name, color: t.color}
+		latency[n] = series{name: t.name, color: t.color}
+	}
+
+	previous := 0.0
+	for _, i := range r.Intervals {
+		length := i.Time - previous
+		previous = i.Time
+		if length <= 0 {
+			length = 1
+		}
+
+		xs = append(xs, i.Time)
+		total.values = append(total.values, i.TPS)
+
+		var count int64
+		for n, t := range types {
+			count += i.Transactions[t.name].Count
+			throughput[n].values = append(throughput[n].values, float64(i.Transactions[t.name].Count)/length)
+			latency[n].values = append(latency[n].values, i.Transactions[t.name].Latency)
+		}
+
+		rate := 0.0
+		if count > 0 {
+			rate = float64(i.Failed) / float64(count) * 100
+		}
+		failures.values = append(failures.values, rate)
+	}
+
+	p.Charts = []template.HTML{
+		chart("Throughput", "transactions/s", xs, append([]series{total}, throughput...)),
+		chart("Latency "+p.Percentile, "ms", xs, latency),
+		chart("Failure rate", "% of transactions", xs, []series{failures}),
+	}
+
+	return pageTemplate.Execute(w, p)
+}
+
+const (
+	width  = 860
+	height = 280
+	left   = 70
+	right  = 130
+	top    = 30
+	bottom = 40
+	yTicks = 5
+	xTicks = 10
+)
+
+// chart renders series over xs as an SVG line chart
+func chart(title string, unit string, xs []float64, ss []series) template.HTML {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height, width, height)
+	fmt.Fprintf(&b, `<text x="%d" y="18" class="title">%s</text>`, left, template.HTMLEscapeString(title))
+
+	if len(xs) == 0 {
+		fmt.Fprintf(&b, `<text x="%d" y="%d">no intervals</text></svg>`, left, height/2)
+		return template.HTML(b.String())
+	}
+
+	maxX := xs[len(xs)-1]
+	minX := xs[0]
+	if maxX == minX {
+		minX = 0
+	}
+
+	maxY := 0.0
+	for _, s := range ss {
+		for _, v := range s.values {
+			maxY = math.Max(maxY, v)
+		}
+	}
+	maxY = niceCeil(maxY)
+
+	plotW := float64(width - left - right)
+	plotH := float64(height - top - bottom)
+	x := func(v float64) float64 { return float64(left) + (v-minX)/(maxX-minX)*plotW }
+	y := func(v float64) float64 { return float64(top) + plotH - v/maxY*plotH }
+
+	for i := 0; i <= yTicks; i++ {
+		v := maxY / yTicks * float64(i)
+		fmt.Fprintf(&b, `<line x1="%d" x2="%.1f" y1="%.1f" y2="%.1f" class="grid"/>`, left, float64(left)+plotW, y(v), y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" class="tick" text-anchor="end">%s</text>`, left-6, y(v)+4, format(v))
+	}
+
+	step := int(math.Ceil(float64(len(xs)) / xTicks))
+	for i := 0; i < len(xs); i += step {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" class="tick" text-anchor="middle">%gs</text>`, x(xs[i]), height-bottom+16, xs[i])
+	}
+
+	fmt.Fprintf(&b, `<text x="14" y="%.1f" class="tick" transform="rotate(-90 14 %.1f)" text-anchor="middle">%s</text>`,
+		float64(top)+plotH/2, float64(top)+plotH/2, template.HTMLEscapeString(unit))
+
+	for n, s := range ss {
+		var points []string
+		for i, v := range s.values {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(xs[i]), y(v)))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" stroke="%s" class="line"/>`, strings.Join(points, " "), s.color)
+
+		ly := top + 10 + n*18
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%d" y2="%d" stroke="%s" class="line"/>`, width-right+12, width-right+28, ly, ly, s.color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" class="tick">%s</text>`, width-right+34, ly+4, template.HTMLEscapeString(s.name))
+	}
+
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+// niceCeil rounds v up to 1, 2 or 5 times a power of ten, so the ticks are round numbers
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+
+	e := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*e {
+			return m * e
+		}
+	}
+
+	return 10 * e
+}
+
+func format(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+
+	return fmt.Sprintf("%.2f", v)
+}
+
+var pageTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 4px 10px; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+td.sample { text-align: left; font-family: monospace; }
+svg { display: block; margin-bottom: 2em; }
+svg .title { font-weight: bold; font-size: 14px; }
+svg .tick { font-size: 11px; fill: #555; }
+svg .grid { stroke: #e5e5e5; }
+svg .line { fill: none; stroke-width: 1.5; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+
+<table>
+<tr><th>duration</th><td>{{printf "%g" .Duration}}s</td></tr>
+<tr><th>tpmC</th><td>{{printf "%.2f" .TpmC}}</td></tr>
+<tr><th>failed</th><td>{{.Failed}}</td></tr>
+</table>
+
+<h2>Transactions</h2>
+<table>
+<tr><th>type</th><th>count</th><th>tps</th><th>mean ms</th><th>p50</th><th>p90</th><th>p95</th><th>p99</th><th>p99.9</th><th>max</th></tr>
+{{range .Summary}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{printf "%.2f" .TPS}}</td>
+{{with .Latency}}<td>{{printf "%.2f" .Mean}}</td><td>{{printf "%.2f" .P50}}</td><td>{{printf "%.2f" .P90}}</td><td>{{printf "%.2f" .P95}}</td><td>{{printf "%.2f" .P99}}</td><td>{{printf "%.2f" .P999}}</td><td>{{printf "%.2f" .Max}}</td>{{else}}<td colspan="7">-</td>{{end}}</tr>
+{{end}}</table>
+
+{{if .OutcomeRows}}<h2>Outcomes</h2>
+<table>
+<tr><th>type</th>{{range .Outcomes}}<th>{{.}}</th>{{end}}</tr>
+{{range .OutcomeRows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+{{if .Errors}}<h2>Errors</h2>
+<table>
+<tr><th>type</th><th>class</th><th>count</th><th>sample</th></tr>
+{{range .Errors}}<tr><td>{{.Type}}</td><td>{{.Class}}</td><td>{{.Count}}</td><td class="sample">{{.Sample}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Over time</h2>
+{{range .Charts}}{{.}}
+{{end}}
+{{if .Config}}<h2>Configuration</h2>
+<table>
+{{range .Config}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,90 @@
+package stats
+
+import "sort"
+
+// sampleLength limits the length of the sample message of an ErrorCount
+const sampleLength = 200
+
+// ErrorCount counts the failed transactions of a type with the same error class
+type ErrorCount struct {
+	Type  string `json:"type"`
+	Class string `json:"class"`
+	Count int64  `json:"count"`
+	// Sample is the message of the first of the errors
+	Sample string `json:"sample"`
+}
+
+// Breakdown counts the outcomes of the transactions and their errors by type
+type Breakdown struct {
+	// Outcomes counts by type and outcome
+	Outcomes map[string]map[string]int64
+	errors   map[[2]string]*ErrorCount
+}
+
+func NewBreakdown() *Breakdown {
+	return &Breakdown{
+		Outcomes: map[string]map[string]int64{},
+		errors:   map[[2]string]*ErrorCount{},
+	}
+}
+
+// Add counts a transaction, class and message are empty unless it failed
+func (b *Breakdown) Add(name string, outcome string, class string, message string) {
+	if b.Outcomes[name] == nil {
+		b.Outcomes[name] = map[string]int64{}
+	}
+	b.Outcomes[name][outcome]++
+
+	if class == "" {
+		return
+	}
+
+	e, ok := b.errors[[2]string{name, class}]
+	if !ok {
+		if len(message) > sampleLength {
+			message = message[:sampleLength] + "..."
+		}
+		e = &ErrorCount{Type: name, Class: class, Sample: message}
+		b.errors[[2]string{name, class}] = e
+	}
+	e.Count++
+}
+
+// Errors returns the error counts sorted by type and class
+func (b *Breakdown) Errors() []ErrorCount {
+	errors := []ErrorCount{}
+	for _, e := range b.errors {
+		errors = append(errors, *e)
+	}
+
+	sort.Slice(errors, func(i, j int) bool {
+		if errors[i].Type != errors[j].Type {
+			return errors[i].Type < errors[j].Type
+		}
+		return errors[i].Class < errors[j].Class
+	})
+
+	return errors
+}
+
+// Merge adds the outcomes and errors of another breakdown, e.g. of another client. The sample of an error that is
+// counted in both is kept.
+func (b *Breakdown) Merge(outcomes map[string]map[string]int64, errors []ErrorCount) {
+	for name, counts := range outcomes {
+		if b.Outcomes[name] == nil {
+			b.Outcomes[name] = map[string]int64{}
+		}
+		for outcome, n := range counts {
+			b.Outcomes[name][outcome] += n
+		}
+	}
+
+	for _, o := range errors {
+		e, ok := b.errors[[2]string{o.Type, o.Class}]
+		if !ok {
+			e = &ErrorCount{Type: o.Type, Class: o.Class, Sample: o.Sample}
+			b.errors[[2]string{o.Type, o.Class}] = e
+		}
+		e.Count += o.Count
+	}
+}
@@ -0,0 +1,117 @@
+package stats
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is the record of one transaction in the event log
+type Event struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Type      string    `json:"type"`
+	Worker    int       `json:"worker"`
+	Warehouse int       `json:"warehouse"`
+	// District is 0 for transactions spanning all districts of the warehouse
+	District int    `json:"district"`
+	Retries  int    `json:"retries"`
+	Outcome  string `json:"outcome"`
+	// ErrorClass is the class of the error of a failed transaction, e.g. conflict, not its message
+	ErrorClass string `json:"error_class,omitempty"`
+}
+
+var eventColumns = []string{"start", "end", "type", "worker", "warehouse", "district", "retries", "outcome", "error_class"}
+
+// EventLog writes one record per transaction as NDJSON or CSV. It is not safe for concurrent use.
+type EventLog struct {
+	f    *os.File
+	gz   *gzip.Writer
+	w    *bufio.Writer
+	json *json.Encoder
+	csv  *csv.Writer
+}
+
+// NewEventLog creates the event log at path, format is ndjson or csv. A path ending in .gz is compressed with gzip.
+func NewEventLog(path string, format string) (*EventLog, error) {
+	if format != "ndjson" && format != "csv" {
+		return nil, fmt.Errorf("unknown event log format %q", format)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &EventLog{f: f}
+
+	var w io.Writer = f
+	if strings.HasSuffix(path, ".gz") {
+		l.gz = gzip.NewWriter(f)
+		w = l.gz
+	}
+	l.w = bufio.NewWriter(w)
+
+	if format == "csv" {
+		l.csv = csv.NewWriter(l.w)
+		err = l.csv.Write(eventColumns)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	} else {
+		l.json = json.NewEncoder(l.w)
+	}
+
+	return l, nil
+}
+
+func (l *EventLog) Write(e Event) error {
+	if l.json != nil {
+		return l.json.Encode(e)
+	}
+
+	return l.csv.Write([]string{
+		e.Start.Format(time.RFC3339Nano),
+		e.End.Format(time.RFC3339Nano),
+		e.Type,
+		strconv.Itoa(e.Worker),
+		strconv.Itoa(e.Warehouse),
+		strconv.Itoa(e.District),
+		strconv.Itoa(e.Retries),
+		e.Outcome,
+		e.ErrorClass,
+	})
+}
+
+// Close flushes the buffered records and closes the file
+func (l *EventLog) Close() error {
+	if l.csv != nil {
+		l.csv.Flush()
+		if err := l.csv.Error(); err != nil {
+			l.f.Close()
+			return err
+		}
+	}
+
+	if err := l.w.Flush(); err != nil {
+		l.f.Close()
+		return err
+	}
+
+	if l.gz != nil {
+		if err := l.gz.Close(); err != nil {
+			l.f.Close()
+			return err
+		}
+	}
+
+	return l.f.Close()
+}
@@ -0,0 +1,221 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	// latencies are recorded in microseconds from 1µs up to an hour with 3 significant digits
+	lowest  = 1
+	highest = 3600 * 1000 * 1000
+	digits  = 3
+)
+
+// Histogram is a mergeable HDR histogram of latencies in milliseconds. Its memory does not depend on the number of
+// recorded values.
+type Histogram struct {
+	h *hdrhistogram.Histogram
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{h: hdrhistogram.New(lowest, highest, digits)}
+}
+
+// Record adds a latency in milliseconds, latencies above an hour are recorded as an hour
+func (h *Histogram) Record(ms float64) {
+	us := int64(ms * 1000)
+	if us < lowest {
+		us = lowest
+	}
+	if us > highest {
+		us = highest
+	}
+
+	h.h.RecordValue(us)
+}
+
+// Merge adds all values recorded in o
+func (h *Histogram) Merge(o *Histogram) {
+	h.h.Merge(o.h)
+}
+
+func (h *Histogram) Reset() {
+	h.h.Reset()
+}
+
+func (h *Histogram) Count() int64 {
+	return h.h.TotalCount()
+}
+
+// Percentile returns the latency in milliseconds below which p percent of the values fall, 0 if nothing was recorded
+func (h *Histogram) Percentile(p float64) float64 {
+	if h.h.TotalCount() == 0 {
+		return 0
+	}
+
+	return float64(h.h.ValueAtPercentile(p)) / 1000
+}
+
+// Summary is the latency distribution of a Histogram in milliseconds
+type Summary struct {
+	Count int64   `json:"count"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	P999  float64 `json:"p99.9"`
+	Max   float64 `json:"max"`
+}
+
+func (h *Histogram) Summary() Summary {
+	if h.h.TotalCount() == 0 {
+		return Summary{}
+	}
+
+	return Summary{
+		Count: h.h.TotalCount(),
+		Mean:  h.h.Mean() / 1000,
+		P50:   h.Percentile(50),
+		P90:   h.Percentile(90),
+		P95:   h.Percentile(95),
+		P99:   h.Percentile(99),
+		P999:  h.Percentile(99.9),
+		Max:   float64(h.h.Max()) / 1000,
+	}
+}
+
+// Encode returns the histogram in the compressed base64 HdrHistogram V2 format, which other HdrHistogram tools read too
+func (h *Histogram) Encode() (string, error) {
+	b, err := h.h.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	return string(b), err
+}
+
+// Decode reads a histogram written by Encode
+func Decode(s string) (*Histogram, error) {
+	h, err := hdrhistogram.Decode([]byte(s))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Histogram{h: h}, nil
+}
+
+// Set holds one histogram per transaction type
+type Set map[string]*Histogram
+
+// Record adds a latency in milliseconds to the histogram of name
+func (s Set) Record(name string, ms float64) {
+	h, ok := s[name]
+	if !ok {
+		h = NewHistogram()
+		s[name] = h
+	}
+
+	h.Record(ms)
+}
+
+// Get returns the histogram of name, an empty one if nothing was recorded
+func (s Set) Get(name string) *Histogram {
+	if h, ok := s[name]; ok {
+		return h
+	}
+
+	return NewHistogram()
+}
+
+// Merge adds all histograms of o
+func (s Set) Merge(o Set) {
+	for name, h := range o {
+		if _, ok := s[name]; !ok {
+			s[name] = NewHistogram()
+		}
+		s[name].Merge(h)
+	}
+}
+
+func (s Set) Reset() {
+	for _, h := range s {
+		h.Reset()
+	}
+}
+
+// Names returns the transaction types of the set, sorted
+func (s Set) Names() []string {
+	var names []string
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// WriteFile stores the set as a JSON object of encoded histograms, see ReadFile
+func (s Set) WriteFile(path string) error {
+	encoded, err := s.Encode()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(encoded, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// ReadFile reads a set written by WriteFile, e.g. to merge the results of several clients
+func ReadFile(path string) (Set, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded map[string]string
+	err = json.Unmarshal(b, &encoded)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	s, err := DecodeSet(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// Encode returns the encoded histogram of every transaction type, see Histogram.Encode
+func (s Set) Encode() (map[string]string, error) {
+	encoded := make(map[string]string, len(s))
+	for name, h := range s {
+		e, err := h.Encode()
+		if err != nil {
+			return nil, err
+		}
+		encoded[name] = e
+	}
+
+	return encoded, nil
+}
+
+// DecodeSet reads a set returned by Set.Encode
+func DecodeSet(encoded map[string]string) (Set, error) {
+	s := make(Set, len(encoded))
+	for name, e := range encoded {
+		h, err := Decode(e)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode histogram %s: %w", name, err)
+		}
+		s[name] = h
+	}
+
+	return s, nil
+}
@@ -0,0 +1,115 @@
+package stats
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// phases of a run as exported in the phase label
+const (
+	PhaseLoad = "load"
+	PhaseRun  = "run"
+	PhaseDone = "done"
+)
+
+var phases = []string{PhaseLoad, PhaseRun, PhaseDone}
+
+// Metrics exports the progress of a run in the Prometheus exposition format. A nil *Metrics records nothing, so
+// callers don't have to check whether metrics are enabled.
+type Metrics struct {
+	registry     *prometheus.Registry
+	transactions *prometheus.CounterVec
+	retries      *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+	workers      prometheus.Gauge
+	phase        *prometheus.GaugeVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		transactions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tpcc_transactions_total",
+			Help: "Transactions by type and outcome (committed, retried_committed, expected_rollback, aborted, error).",
+		}, []string{"type", "outcome"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tpcc_transaction_retries_total",
+			Help: "Times a transaction was run again after a retryable error, by type.",
+		}, []string{"type"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tpcc_transaction_duration_seconds",
+			Help:    "Latency of the transactions by type, including retries.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 16),
+		}, []string{"type"}),
+		workers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tpcc_workers_active",
+			Help: "Workers currently running transactions.",
+		}),
+		phase: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tpcc_phase",
+			Help: "1 for the current phase of the run (load, run, done), 0 for the others.",
+		}, []string{"phase"}),
+	}
+
+	m.registry.MustRegister(m.transactions, m.retries, m.latency, m.workers, m.phase)
+	m.SetPhase(PhaseLoad)
+
+	return m
+}
+
+// Serve serves /metrics on addr in the background. Only failing to listen is returned.
+func (m *Metrics) Serve(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+
+	go http.Serve(l, mux)
+
+	return nil
+}
+
+// Observe records a finished transaction, ms is its latency in milliseconds
+func (m *Metrics) Observe(name string, outcome string, retries int, ms float64) {
+	if m == nil {
+		return
+	}
+
+	m.transactions.WithLabelValues(name, outcome).Inc()
+	if retries > 0 {
+		m.retries.WithLabelValues(name).Add(float64(retries))
+	}
+	m.latency.WithLabelValues(name).Observe(ms / 1000)
+}
+
+func (m *Metrics) WorkerStarted() {
+	if m != nil {
+		m.workers.Inc()
+	}
+}
+
+func (m *Metrics) WorkerStopped() {
+	if m != nil {
+		m.workers.Dec()
+	}
+}
+
+func (m *Metrics) SetPhase(phase string) {
+	if m == nil {
+		return
+	}
+
+	for _, p := range phases {
+		v := 0.0
+		if p == phase {
+			v = 1
+		}
+		m.phase.WithLabelValues(p).Set(v)
+	}
+}
@@ -0,0 +1,264 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the json output of run. It changes when a field is renamed or removed or changes
+// its meaning, adding fields keeps the version.
+const SchemaVersion = 1
+
+// types of the records of the json output
+const (
+	HeaderRecord   = "header"
+	IntervalRecord = "interval"
+	SummaryRecord  = "summary"
+)
+
+// RunConfig is the configuration of a run. Credentials and the URI are left out.
+type RunConfig struct {
+	DB             string  `json:"db"`
+	Warehouses     int     `json:"warehouses"`
+	ScaleFactor    float64 `json:"scalefactor"`
+	Threads        int     `json:"threads"`
+	Time           int     `json:"time"`
+	ReportInterval int     `json:"reportInterval"`
+	Percentile     float64 `json:"percentile"`
+	PercentFail    int     `json:"percentFail"`
+	Transactions   bool    `json:"trx"`
+	TrxMode        string  `json:"trxMode"`
+	FindAndModify  bool    `json:"findandmodify"`
+	Aggregate      bool    `json:"aggregate"`
+	// Rate is the scheduled transactions per second of an open-loop run and Arrival its arrival process
+	Rate    float64 `json:"rate,omitempty"`
+	Arrival string  `json:"arrival,omitempty"`
+	// Profile is the --profile of a run with changing threads
+	Profile string `json:"profile,omitempty"`
+	// Agents is the number of agents of a distributed run, Threads are the threads of each of them
+	Agents int `json:"agents,omitempty"`
+}
+
+// Header is the first record of the json output
+type Header struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	// RunID identifies the run, e.g. to find its event log or histograms
+	RunID  string    `json:"runId"`
+	Start  time.Time `json:"start"`
+	Seed   int64     `json:"seed"`
+	Driver string    `json:"driver"`
+	// ServerVersion is empty if the driver can't tell
+	ServerVersion string    `json:"serverVersion"`
+	Config        RunConfig `json:"config"`
+}
+
+// Interval is the record of one report interval
+type Interval struct {
+	Type string `json:"type"`
+	// Time is the end of the interval in seconds since the start of the run
+	Time         float64                         `json:"time"`
+	TPS          float64                         `json:"tps"`
+	Failed       int64                           `json:"failed"`
+	Transactions map[string]IntervalTransactions `json:"transactions"`
+	// Backlog is the number of transactions behind schedule at the end of the interval, only in an open-loop run
+	Backlog *int `json:"backlog,omitempty"`
+	// Stage of the profile, counted from 1, and its Threads in the middle of the interval, only with a profile
+	Stage   int `json:"stage,omitempty"`
+	Threads int `json:"threads,omitempty"`
+}
+
+// IntervalTransactions are the transactions of one type in an interval
+type IntervalTransactions struct {
+	Count int64 `json:"count"`
+	// Latency is the latency at the percentile of the configuration in milliseconds
+	Latency float64 `json:"latency"`
+}
+
+// RunSummary is the last record of the json output
+type RunSummary struct {
+	Type string `json:"type"`
+	// Duration is the reported time in seconds
+	Duration     float64            `json:"duration"`
+	TpmC         float64            `json:"tpmC"`
+	Failed       int64              `json:"failed"`
+	Transactions map[string]Summary `json:"transactions"`
+	// Outcomes counts the transactions by type and outcome
+	Outcomes map[string]map[string]int64 `json:"outcomes"`
+	Errors   []ErrorCount                `json:"errors"`
+	// Backlog is the number of transactions behind schedule at the end, only in an open-loop run
+	Backlog *int `json:"backlog,omitempty"`
+}
+
+// Result is a run read back from its --report-format json output
+type Result struct {
+	// Header is nil for the output of versions without a schema
+	Header    *Header
+	Intervals []Interval
+	// Duration is the reported time in seconds
+	Duration float64
+	// Transactions is the number of transactions by type
+	Transactions map[string]int64
+	Failed       int64
+	// Latency is the distribution of the whole run by type, nil if the output has no summary
+	Latency map[string]Summary
+	// Outcomes and Errors break the transactions down, nil if the output has no summary or predates them
+	Outcomes map[string]map[string]int64
+	Errors   []ErrorCount
+}
+
+// ReadResult reads the JSON output of run, lines that are not JSON objects are skipped.
+// The output of versions without a schema is read too.
+func ReadResult(path string) (*Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &Result{Transactions: map[string]int64{}}
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := []byte(strings.TrimSpace(scanner.Text()))
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		var record struct {
+			Type string `json:"type"`
+		}
+		err = json.Unmarshal(line, &record)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+
+		switch record.Type {
+		case HeaderRecord:
+			r.Header = &Header{}
+			err = json.Unmarshal(line, r.Header)
+			if err == nil && r.Header.Version > SchemaVersion {
+				err = fmt.Errorf("schema version %d is newer than %d", r.Header.Version, SchemaVersion)
+			}
+		case IntervalRecord:
+			var i Interval
+			err = json.Unmarshal(line, &i)
+			r.add(i)
+		case SummaryRecord:
+			var s RunSummary
+			err = json.Unmarshal(line, &s)
+			r.Latency = s.Transactions
+			r.Outcomes = s.Outcomes
+			r.Errors = s.Errors
+		case "":
+			err = r.readLegacy(line)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if r.Duration == 0 {
+		return nil, fmt.Errorf("%s: no report intervals found", path)
+	}
+
+	return r, nil
+}
+
+func (r *Result) add(i Interval) {
+	r.Intervals = append(r.Intervals, i)
+	r.Duration = i.Time
+	r.Failed += i.Failed
+
+	for name, t := range i.Transactions {
+		r.Transactions[name] += t.Count
+	}
+}
+
+// the lines printed by versions without a schema
+type legacyInterval struct {
+	Time   *float64 `json:"time"`
+	TPS    float64  `json:"tps"`
+	Failed int64    `json:"Failed"`
+}
+
+type legacyTransactions struct {
+	Trx               int64   `json:"Trx"`
+	LatencyPercentile float64 `json:"LatencyPercentile"`
+}
+
+type legacySummary struct {
+	Trx  int64   `json:"Trx"`
+	Mean float64 `json:"Mean"`
+	P50  float64 `json:"P50"`
+	P90  float64 `json:"P90"`
+	P95  float64 `json:"P95"`
+	P99  float64 `json:"P99"`
+	P999 float64 `json:"P99.9"`
+	Max  float64 `json:"Max"`
+}
+
+func (r *Result) readLegacy(line []byte) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(line, &fields)
+	if err != nil {
+		return err
+	}
+
+	if s, ok := fields["summary"]; ok {
+		var types map[string]legacySummary
+		err = json.Unmarshal(s, &types)
+		if err != nil {
+			return err
+		}
+
+		r.Latency = map[string]Summary{}
+		for name, t := range types {
+			r.Latency[name] = Summary{Count: t.Trx, Mean: t.Mean, P50: t.P50, P90: t.P90, P95: t.P95, P99: t.P99, P999: t.P999, Max: t.Max}
+		}
+		return nil
+	}
+
+	var l legacyInterval
+	err = json.Unmarshal(line, &l)
+	if err != nil || l.Time == nil {
+		// e.g. the configuration, which has no use without a schema
+		return nil
+	}
+
+	i := Interval{
+		Type:         IntervalRecord,
+		Time:         *l.Time,
+		TPS:          l.TPS,
+		Failed:       l.Failed,
+		Transactions: map[string]IntervalTransactions{},
+	}
+	for name, raw := range fields {
+		var t legacyTransactions
+		if raw[0] == '{' && json.Unmarshal(raw, &t) == nil {
+			i.Transactions[name] = IntervalTransactions{Count: t.Trx, Latency: t.LatencyPercentile}
+		}
+	}
+	r.add(i)
+
+	return nil
+}
+
+// Throughput returns the transactions of a type per second
+func (r *Result) Throughput(name string) float64 {
+	return float64(r.Transactions[name]) / r.Duration
+}
+
+// TpmC returns the New-Order transactions per minute
+func (r *Result) TpmC() float64 {
+	return r.Throughput("NewOrder") * 60
+}
@@ -0,0 +1,106 @@
+package tpcc
+
+import (
+	"context"
+	"sync"
+)
+
+// Control pauses the workers of a run and changes their mix while it runs. The workers share it, a nil *Control
+// never pauses and runs DefaultMix.
+type Control struct {
+	mu sync.RWMutex
+	// resume is closed when a paused run resumes, nil while running
+	resume chan struct{}
+	mix    Mix
+}
+
+func NewControl() *Control {
+	return &Control{mix: DefaultMix}
+}
+
+// Pause makes the workers wait before their next transaction, running transactions finish
+func (c *Control) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resume == nil {
+		c.resume = make(chan struct{})
+	}
+}
+
+// Resume lets paused workers continue
+func (c *Control) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resume != nil {
+		close(c.resume)
+		c.resume = nil
+	}
+}
+
+func (c *Control) Paused() bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.resume != nil
+}
+
+// wait blocks while the run is paused. It returns false if ctx is done or stop is closed first.
+func (c *Control) wait(ctx context.Context, stop <-chan struct{}) bool {
+	if c == nil {
+		return true
+	}
+
+	c.mu.RLock()
+	resume := c.resume
+	c.mu.RUnlock()
+
+	if resume == nil {
+		return true
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-stop:
+		return false
+	case <-resume:
+		return true
+	}
+}
+
+func (c *Control) Mix() Mix {
+	if c == nil {
+		return DefaultMix
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.mix
+}
+
+// ChangeMix makes the workers pick their next transactions by mix
+func (c *Control) ChangeMix(mix Mix) error {
+	err := mix.Validate()
+	if err != nil {
+		return err
+	}
+
+	// the workers keep reading the old map, it must not change
+	m := Mix{}
+	for t, weight := range mix {
+		m[t] = weight
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.mix = m
+	return nil
+}
@@ -0,0 +1,75 @@
+package tpcc
+
+import (
+	"fmt"
+
+	"github.com/Percona-Lab/go-tpcc/helpers"
+)
+
+// Mix is the relative weight of every transaction type, types that are missing are not run
+type Mix map[TransactionType]int
+
+// DefaultMix is the mix of the TPC-C specification
+var DefaultMix = Mix{
+	StockLevelTrx:  4,
+	DeliveryTrx:    4,
+	OrderStatusTrx: 4,
+	PaymentTrx:     43,
+	NewOrderTrx:    45,
+}
+
+// Validate checks that the weights are not negative and not all 0
+func (m Mix) Validate() error {
+	total := 0
+	for t, weight := range m {
+		if weight < 0 {
+			return fmt.Errorf("weight of %s is negative", t)
+		}
+		total += weight
+	}
+
+	if total == 0 {
+		return fmt.Errorf("mix has no transactions")
+	}
+
+	return nil
+}
+
+// pick returns a random transaction type by the weights of the mix
+func (m Mix) pick() TransactionType {
+	total := 0
+	for _, t := range TransactionTypes {
+		total += m[t]
+	}
+
+	r := helpers.RandInt(1, total)
+	for _, t := range TransactionTypes {
+		if r <= m[t] {
+			return t
+		}
+		r -= m[t]
+	}
+
+	return NewOrderTrx
+}
+
+// MarshalText makes transaction types the names of a json object
+func (t TransactionType) MarshalText() ([]byte, error) {
+	name, ok := transactionNames[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown transaction type %d", int(t))
+	}
+
+	return []byte(name), nil
+}
+
+func (t *TransactionType) UnmarshalText(text []byte) error {
+	for k, name := range transactionNames {
+		if name == string(text) {
+			*t = k
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown transaction type %s", text)
+}
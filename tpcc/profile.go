@@ -0,0 +1,84 @@
+package tpcc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stage is a part of a Profile, the number of threads goes linearly from From to To over Duration. From and To are
+// equal for a stage with a fixed number of threads.
+type Stage struct {
+	From     int
+	To       int
+	Duration time.Duration
+}
+
+// Profile is a sequence of stages that changes the number of threads during a run
+type Profile []Stage
+
+// ParseProfile parses comma separated stages of the form threads:duration for a fixed number of threads or
+// from-to:duration for a linear ramp, e.g. "8:60s,16:60s,32:60s" or "1-64:10m".
+func ParseProfile(s string) (Profile, error) {
+	var p Profile
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.SplitN(strings.TrimSpace(part), ":", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("stage %q is not threads:duration", part)
+		}
+
+		d, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("stage %q: %w", part, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("stage %q: duration has to be positive", part)
+		}
+
+		threads := strings.SplitN(fields[0], "-", 2)
+		from, err := strconv.Atoi(threads[0])
+		if err != nil {
+			return nil, fmt.Errorf("stage %q: %w", part, err)
+		}
+		to := from
+		if len(threads) == 2 {
+			to, err = strconv.Atoi(threads[1])
+			if err != nil {
+				return nil, fmt.Errorf("stage %q: %w", part, err)
+			}
+		}
+		if from < 1 || to < 1 {
+			return nil, fmt.Errorf("stage %q: needs at least one thread", part)
+		}
+
+		p = append(p, Stage{From: from, To: to, Duration: d})
+	}
+
+	return p, nil
+}
+
+// Duration is the total duration of all stages
+func (p Profile) Duration() time.Duration {
+	var d time.Duration
+	for _, s := range p {
+		d += s.Duration
+	}
+
+	return d
+}
+
+// At returns the stage, counted from 1, and the number of threads at elapsed since the start of the run.
+// The last stage holds its final number of threads once the profile is over.
+func (p Profile) At(elapsed time.Duration) (int, int) {
+	for n, s := range p {
+		if elapsed < s.Duration {
+			threads := float64(s.From) + float64(s.To-s.From)*float64(elapsed)/float64(s.Duration)
+			return n + 1, int(math.Round(threads))
+		}
+		elapsed -= s.Duration
+	}
+
+	return len(p), p[len(p)-1].To
+}
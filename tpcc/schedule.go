@@ -0,0 +1,137 @@
+package tpcc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Percona-Lab/go-tpcc/helpers"
+)
+
+// arrival processes of a Schedule
+const (
+	ConstantArrival = "constant"
+	PoissonArrival  = "poisson"
+)
+
+// MaxRate is the highest rate of a Schedule, its start times are at least a nanosecond apart
+const MaxRate = 1e9
+
+// Schedule hands out the intended start times of an open-loop run at a fixed aggregate rate. The workers share it,
+// each one takes the next start time when it is free, so a stalled database makes the transactions start late
+// instead of fewer transactions being issued.
+type Schedule struct {
+	mu       sync.Mutex
+	rate     float64
+	interval time.Duration
+	poisson  bool
+	// next is the start time of the next transaction, the missed ones before it are not kept
+	next time.Time
+}
+
+// NewSchedule returns a schedule of rate transactions per second starting at start. arrival is ConstantArrival
+// for evenly spaced start times or PoissonArrival for exponentially distributed gaps with the same mean.
+func NewSchedule(rate float64, arrival string, start time.Time) (*Schedule, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate has to be positive")
+	}
+	if rate > MaxRate {
+		return nil, fmt.Errorf("rate has to be at most %g", MaxRate)
+	}
+	if arrival != ConstantArrival && arrival != PoissonArrival {
+		return nil, fmt.Errorf("unknown arrival process %s", arrival)
+	}
+
+	s := &Schedule{
+		rate:     rate,
+		interval: time.Duration(float64(time.Second) / rate),
+		poisson:  arrival == PoissonArrival,
+	}
+	s.next = start.Add(s.gap())
+
+	return s, nil
+}
+
+// gap draws the time between two start times
+func (s *Schedule) gap() time.Duration {
+	if s.poisson {
+		return time.Duration(helpers.RandExpFloat(float64(s.interval)))
+	}
+
+	return s.interval
+}
+
+// Rate is the scheduled transactions per second, 0 for a nil schedule
+func (s *Schedule) Rate() float64 {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rate
+}
+
+// ChangeRate schedules rate transactions per second from now on, the number of transactions behind schedule is kept
+func (s *Schedule) ChangeRate(rate float64, now time.Time) error {
+	if rate <= 0 {
+		return fmt.Errorf("rate has to be positive")
+	}
+	if rate > MaxRate {
+		return fmt.Errorf("rate has to be at most %g", MaxRate)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backlog := s.backlog(now)
+
+	s.rate = rate
+	s.interval = time.Duration(float64(time.Second) / rate)
+	if backlog == 0 {
+		s.next = now.Add(s.gap())
+	} else {
+		s.next = now.Add(-time.Duration(backlog-1) * s.interval)
+	}
+	return nil
+}
+
+// Restart drops the transactions behind schedule and schedules the next ones from now on, e.g. after a pause
+func (s *Schedule) Restart(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next = now.Add(s.gap())
+}
+
+// Next takes the next intended start time, it may lie in the past if the workers fall behind
+func (s *Schedule) Next() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.next
+	s.next = s.next.Add(s.gap())
+	return next
+}
+
+// Backlog is the number of transactions that should have started by now but did not, 0 for a nil schedule.
+// It is exact for constant arrival and the expected number for Poisson arrival.
+func (s *Schedule) Backlog(now time.Time) int {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.backlog(now)
+}
+
+func (s *Schedule) backlog(now time.Time) int {
+	if s.next.After(now) {
+		return 0
+	}
+
+	return int(now.Sub(s.next)/s.interval) + 1
+}
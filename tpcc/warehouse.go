@@ -3,7 +3,6 @@ package tpcc
 import (
 	"context"
 	"math/rand"
-	"time"
 
 	"github.com/Percona-Lab/go-tpcc/helpers"
 	"github.com/Percona-Lab/go-tpcc/tpcc/models"
@@ -73,7 +72,6 @@ func (w *Worker) LoadWarehouse(ctx context.Context, id int) error {
 			return err
 		}
 
-		rand.Seed(time.Now().UnixNano())
 		rand.Shuffle(len(customersId), func(i, j int) { customersId[i], customersId[j] = customersId[j], customersId[i] })
 		for c := 1; c < w.sc.CustomersPerDistrict+1; c++ {
 			orderCount := helpers.RandInt(MIN_OL_CNT, MAX_OL_CNT)
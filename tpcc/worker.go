@@ -2,6 +2,7 @@ package tpcc
 
 import (
 	"context"
+	"crypto/tls"
 	"sync"
 	"time"
 
@@ -13,6 +14,9 @@ import (
 type Configuration struct {
 	DBDriver       string
 	URI            string
+	User           string
+	Password       string
+	TLS            *tls.Config
 	Transactions   bool
 	DBName         string
 	Threads        int
@@ -22,6 +26,18 @@ type Configuration struct {
 	WareHouses     int
 	ScaleFactor    float64
 	PercentFail    int
+	FindAndModify  bool
+	TrxMode        string
+	Aggregate      bool
+	Shard          bool
+	ShardZones     bool
+	Debug          bool
+	// DriverParams are passed to the driver as databases.Options.Params
+	DriverParams map[string]string
+	// WarehouseFrom and WarehouseTo limit the home warehouses of the transactions, e.g. to split them between
+	// several clients. All warehouses are used if they are 0, remote warehouses are always taken from all of them.
+	WarehouseFrom int
+	WarehouseTo   int
 }
 
 type Worker struct {
@@ -33,6 +49,15 @@ type Worker struct {
 	wg           *sync.WaitGroup
 	c            chan Transaction
 	denormalized bool
+	// warehouse and district of the running transaction, district is 0 if it spans all districts
+	warehouseId int
+	districtId  int
+	// schedule makes the worker run open-loop if it is not nil
+	schedule *Schedule
+	// stop ends Execute after the running transaction, unlike cancelling its context
+	stop <-chan struct{}
+	// control pauses the worker and picks the mix, nil runs DefaultMix without pauses
+	control *Control
 }
 
 func NewWorker(configuration *Configuration, wg *sync.WaitGroup, c chan Transaction, threadId int) (*Worker, error) {
@@ -46,12 +71,23 @@ func NewWorker(configuration *Configuration, wg *sync.WaitGroup, c chan Transact
 		INITIAL_NEW_ORDERS_PER_DISTRICT,
 	)
 
-	den := false
-	if configuration.DBDriver == "mongodb" {
-		den = true
-	}
-
-	d, err := databases.NewDatabase(configuration.DBDriver, configuration.URI, configuration.DBName, "a", "b", configuration.Transactions, false)
+	caps, _ := databases.Lookup(configuration.DBDriver)
+
+	d, err := databases.NewDatabase(configuration.DBDriver, databases.Options{
+		URI:           configuration.URI,
+		DBName:        configuration.DBName,
+		Username:      configuration.User,
+		Password:      configuration.Password,
+		TLS:           configuration.TLS,
+		Transactions:  configuration.Transactions,
+		FindAndModify: configuration.FindAndModify,
+		Aggregate:     configuration.Aggregate,
+		Shard:         configuration.Shard,
+		ShardZones:    configuration.ShardZones,
+		WareHouses:    configuration.WareHouses,
+		Debug:         configuration.Debug,
+		Params:        configuration.DriverParams,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +95,9 @@ func NewWorker(configuration *Configuration, wg *sync.WaitGroup, c chan Transact
 	if err != nil {
 		return nil, err
 	}
+	ex.ChangeTransactions(configuration.Transactions)
+	ex.ChangeTrxCallback(configuration.TrxMode == "callback")
+	ex.ChangeFindAndModify(configuration.FindAndModify)
 
 	w := &Worker{
 		threadId:     threadId,
@@ -67,7 +106,7 @@ func NewWorker(configuration *Configuration, wg *sync.WaitGroup, c chan Transact
 		ex:           ex,
 		wg:           wg,
 		c:            c,
-		denormalized: den,
+		denormalized: caps.Denormalized,
 	}
 
 	return w, nil
@@ -103,18 +142,63 @@ func NewScaleParameters(
 type TransactionType int
 
 const (
-	StockLevelTrx = iota
+	StockLevelTrx TransactionType = iota
 	DeliveryTrx
 	OrderStatusTrx
 	PaymentTrx
 	NewOrderTrx
 )
 
+var transactionNames = map[TransactionType]string{
+	StockLevelTrx:  "StockLevel",
+	DeliveryTrx:    "Delivery",
+	OrderStatusTrx: "OrderStatus",
+	PaymentTrx:     "Payment",
+	NewOrderTrx:    "NewOrder",
+}
+
+func (t TransactionType) String() string {
+	return transactionNames[t]
+}
+
+// TransactionTypes are all transaction types in the order they are reported
+var TransactionTypes = []TransactionType{StockLevelTrx, DeliveryTrx, OrderStatusTrx, PaymentTrx, NewOrderTrx}
+
 type Transaction struct {
 	ThreadId int
 	Type     TransactionType
-	Failed   bool
-	Time     float64
+	// Failed is true unless the transaction committed, see executor.Outcome
+	Failed  bool
+	Outcome executor.Outcome
+	// Retries is how many times the transaction was run again after a retryable error
+	Retries int
+	// ErrorClass is the executor.ErrorClass of the error of a failed transaction and Error its message
+	ErrorClass  string
+	Error       string
+	WarehouseId int
+	DistrictId  int
+	// Start is the intended start time in an open-loop run, so Time includes Wait
+	Start time.Time
+	End   time.Time
+	// Time is the latency in milliseconds
+	Time float64
+	// Wait is how many milliseconds the transaction started behind schedule in an open-loop run
+	Wait float64
+}
+
+// ChangeStop makes Execute return once stop is closed, without cancelling the running transaction
+func (w *Worker) ChangeStop(stop <-chan struct{}) {
+	w.stop = stop
+}
+
+// ChangeControl makes the worker follow the pauses and the mix of c
+func (w *Worker) ChangeControl(c *Control) {
+	w.control = c
+}
+
+// ChangeSchedule makes the worker start its transactions at the times of s instead of one after the other
+func (w *Worker) ChangeSchedule(s *Schedule) {
+	w.schedule = s
 }
 
 func (w *Worker) Execute(ctx context.Context) {
@@ -123,35 +207,69 @@ func (w *Worker) Execute(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
+		case <-w.stop:
+			return
 		default:
+			if !w.control.wait(ctx, w.stop) {
+				return
+			}
+
 			t := time.Now()
-			var status error
+			if w.schedule != nil {
+				intended := w.schedule.Next()
+				if wait := intended.Sub(t); wait > 0 {
+					timer := time.NewTimer(wait)
+					select {
+					case <-ctx.Done():
+						timer.Stop()
+						return
+					case <-w.stop:
+						timer.Stop()
+						return
+					case <-timer.C:
+					}
+				}
+				t = intended
+			}
 			trx := Transaction{
 				ThreadId: w.threadId,
 			}
-			switch r := helpers.RandInt(1, 100); {
-			case r <= 4:
-				trx.Type = StockLevelTrx
-				status = w.DoStockLevelTrx(ctx)
-			case r <= 8:
-				trx.Type = DeliveryTrx
-				status = w.DoDelivery(ctx)
-			case r <= 12:
-				trx.Type = OrderStatusTrx
-				status = w.DoOrderStatus(ctx)
-			case r <= 55:
-				trx.Type = PaymentTrx
-				status = w.DoPayment(ctx)
+			var do func(ctx context.Context) error
+			trx.Type = w.control.Mix().pick()
+			switch trx.Type {
+			case StockLevelTrx:
+				do = w.DoStockLevelTrx
+			case DeliveryTrx:
+				do = w.DoDelivery
+			case OrderStatusTrx:
+				do = w.DoOrderStatus
+			case PaymentTrx:
+				do = w.DoPayment
 			default:
-				trx.Type = NewOrderTrx
-				status = w.DoNewOrder(ctx)
+				do = w.DoNewOrder
 			}
 
-			trx.Time = float64(time.Now().Sub(t).Nanoseconds()) / 1e6
+			if w.schedule != nil {
+				trx.Wait = float64(time.Since(t).Nanoseconds()) / 1e6
+			}
 
-			trx.Failed = false
-			if status != nil {
-				trx.Failed = true
+			result := w.ex.Run(func() error {
+				return do(ctx)
+			})
+
+			end := time.Now()
+			trx.Start = t
+			trx.End = end
+			trx.Time = float64(end.Sub(t).Nanoseconds()) / 1e6
+			trx.WarehouseId = w.warehouseId
+			trx.DistrictId = w.districtId
+
+			trx.Outcome = result.Outcome
+			trx.Failed = result.Outcome.Failed()
+			trx.Retries = result.Retries
+			trx.ErrorClass = result.ErrorClass
+			if result.Err != nil {
+				trx.Error = result.Err.Error()
 			}
 
 			w.c <- trx
@@ -159,24 +277,35 @@ func (w *Worker) Execute(ctx context.Context) {
 	}
 }
 
+// homeWarehouse returns a random warehouse of the range of the configuration
+func (w *Worker) homeWarehouse() int {
+	if w.cfg.WarehouseFrom == 0 {
+		return helpers.RandInt(1, w.sc.Warehouses)
+	}
+
+	return helpers.RandInt(w.cfg.WarehouseFrom, w.cfg.WarehouseTo)
+}
+
 func (w *Worker) DoStockLevelTrx(ctx context.Context) error {
-	warehouseId := helpers.RandInt(1, w.sc.Warehouses)
+	warehouseId := w.homeWarehouse()
 	districtId := helpers.RandInt(1, w.sc.DistrictsPerWarehouse)
 	threshold := helpers.RandInt(MIN_STOCK_LEVEL_THRESHOLD, MAX_STOCK_LEVEL_THRESHOLD)
+	w.warehouseId, w.districtId = warehouseId, districtId
 
 	return w.ex.DoStockLevelTrx(ctx, warehouseId, districtId, threshold)
 }
 
 func (w *Worker) DoDelivery(ctx context.Context) error {
-	warehouseId := helpers.RandInt(1, w.sc.Warehouses)
+	warehouseId := w.homeWarehouse()
 	OCarrierId := helpers.RandInt(MIN_CARRIER_ID, MAX_CARRIER_ID)
 	OlDeliveryD := time.Now()
+	w.warehouseId, w.districtId = warehouseId, 0
 
 	return w.ex.DoDeliveryTrx(ctx, warehouseId, OCarrierId, OlDeliveryD, w.sc.DistrictsPerWarehouse)
 }
 
 func (w *Worker) DoOrderStatus(ctx context.Context) error {
-	wId := helpers.RandInt(1, w.sc.Warehouses)
+	wId := w.homeWarehouse()
 	dId := helpers.RandInt(1, w.sc.DistrictsPerWarehouse)
 	cId := 0
 	cLast := ""
@@ -192,11 +321,12 @@ func (w *Worker) DoOrderStatus(ctx context.Context) error {
 		cId = helpers.RandInt(1, w.sc.CustomersPerDistrict)
 	}
 
+	w.warehouseId, w.districtId = wId, dId
 	return w.ex.DoOrderStatus(ctx, wId, dId, cId, cLast)
 }
 
 func (w *Worker) DoPayment(ctx context.Context) error {
-	wId := helpers.RandInt(1, w.sc.Warehouses)
+	wId := w.homeWarehouse()
 	dId := helpers.RandInt(1, w.sc.DistrictsPerWarehouse)
 	cWId := 0
 	cDId := 0
@@ -204,6 +334,7 @@ func (w *Worker) DoPayment(ctx context.Context) error {
 	cLast := ""
 	hAmount := helpers.RandFloat(MIN_PAYMENT, MAX_PAYMENT, MONEY_DECIMALS)
 	hDate := time.Now()
+	w.warehouseId, w.districtId = wId, dId
 
 	if w.sc.Warehouses == 1 || helpers.RandInt(1, 100) <= 85 {
 		cWId = wId
@@ -228,11 +359,12 @@ func (w *Worker) DoPayment(ctx context.Context) error {
 }
 
 func (w *Worker) DoNewOrder(ctx context.Context) error {
-	wId := helpers.RandInt(1, w.sc.Warehouses)
+	wId := w.homeWarehouse()
 	dId := helpers.RandInt(1, w.sc.DistrictsPerWarehouse)
 	cId := helpers.RandInt(1, w.sc.CustomersPerDistrict)
 	oEntryD := time.Now()
 	olCnt := helpers.RandInt(MIN_OL_CNT, MAX_OL_CNT)
+	w.warehouseId, w.districtId = wId, dId
 
 	rollback := false
 
@@ -265,6 +397,10 @@ func (w *Worker) DoNewOrder(ctx context.Context) error {
 	return w.ex.DoNewOrderTrx(ctx, wId, dId, cId, oEntryD, iIds, iWIds, iQtys)
 }
 
+func (w *Worker) ServerVersion(ctx context.Context) (string, error) {
+	return w.ex.ServerVersion(ctx)
+}
+
 func (w *Worker) CreateIndexes() error {
 	return w.ex.CreateIndexes()
 }